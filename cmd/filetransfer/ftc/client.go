@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skycoin/skycoin/src/util/file"
+	"github.com/skycoin/skywire/pkg/app"
+	"github.com/skycoin/skywire/pkg/net/skycoin-messenger/factory"
+)
+
+const (
+	Version = "1.0.0"
+
+	chunkSize     = 64 * 1024
+	progressEvery = 5 * time.Second
+	retryWait     = 3 * time.Second
+)
+
+var (
+	nodeAddress string
+	// use fixed seed if true
+	seed bool
+	// path for seed, public key and private key
+	seedPath string
+	// connect to node
+	nodeKey string
+	// connect to app
+	appKey string
+
+	discoveryKey string
+
+	// file to fetch and where to save it
+	name string
+	out  string
+
+	retries        int
+	connectTimeout time.Duration
+
+	version bool
+)
+
+// error reported by the server, retrying doesn't help
+type remoteError string
+
+func (e remoteError) Error() string {
+	return string(e)
+}
+
+func parseFlags() {
+	flag.StringVar(&nodeAddress, "node-address", ":5000", "node address to connect")
+	flag.BoolVar(&seed, "seed", true, "use fixed seed to connect if true")
+	flag.StringVar(&seedPath, "seed-path", filepath.Join(file.UserHome(), ".skywire", "ftc", "keys.json"), "path to save seed info")
+	flag.StringVar(&nodeKey, "node-key", "", "connect to node key")
+	flag.StringVar(&appKey, "app-key", "", "connect to app key")
+	flag.StringVar(&discoveryKey, "discovery-key", "", "connect to discovery key")
+	flag.StringVar(&name, "file", "", "name of the file to fetch")
+	flag.StringVar(&out, "out", "", "path to save the file, the file name in the current directory if empty")
+	flag.IntVar(&retries, "retries", 5, "times to resume an interrupted transfer")
+	flag.DurationVar(&connectTimeout, "connect-timeout", time.Minute, "timeout of building the app connection")
+	flag.BoolVar(&version, "v", false, "print current version")
+	flag.Parse()
+}
+
+func main() {
+	parseFlags()
+	if version {
+		fmt.Println(Version)
+		return
+	}
+
+	if len(nodeKey) != 66 || len(appKey) != 66 {
+		log.Fatalf("invalid node-key(%s) or app-key(%s)", nodeKey, appKey)
+	}
+	if len(name) == 0 {
+		log.Fatal("file is empty")
+	}
+	if len(out) == 0 {
+		out = filepath.Base(name)
+	}
+
+	a := app.NewClient(app.Client, "ftc", Version)
+	a.AppConnectionInitCallback = func(resp *factory.AppConnResp) *factory.AppFeedback {
+		return &factory.AppFeedback{
+			Port:   resp.Port,
+			Failed: resp.Failed,
+			Msg:    resp.Msg,
+		}
+	}
+	if !seed {
+		seedPath = ""
+	} else {
+		if len(seedPath) < 1 {
+			seedPath = filepath.Join(file.UserHome(), ".skywire", "ftc", "keys.json")
+		}
+	}
+	err := a.Start(nodeAddress, seedPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	resp, err := a.ConnectToContext(ctx, nodeKey, appKey, discoveryKey)
+	cancel()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = download(net.JoinHostPort(resp.Host, strconv.Itoa(resp.Port)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("saved %s", out)
+}
+
+// Download the file from the server at addr, resuming interrupted transfers
+func download(addr string) (err error) {
+	for i := 0; ; i++ {
+		err = fetch(addr)
+		if err == nil {
+			return
+		}
+		if _, ok := err.(remoteError); ok || i >= retries {
+			return
+		}
+		log.Errorf("transfer err %v, resume in %s", err, retryWait)
+		time.Sleep(retryWait)
+	}
+}
+
+// Fetch the rest of the file into out.part and move it to out when it is
+// complete and verified
+func fetch(addr string) (err error) {
+	part := out + ".part"
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+	offset := info.Size()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	_, err = fmt.Fprintf(conn, "GET %s %d\n", name, offset)
+	if err != nil {
+		return
+	}
+	r := bufio.NewReaderSize(conn, chunkSize)
+	size, hash, err := readHeader(r)
+	if err != nil {
+		if _, ok := err.(remoteError); ok && offset > 0 {
+			// the file may have changed on the server, start over
+			os.Remove(part)
+			err = fmt.Errorf("resume at %d: %v", offset, err)
+		}
+		return
+	}
+
+	buf := make([]byte, chunkSize)
+	start, resumed := time.Now(), offset
+	last := start
+	for offset < size {
+		var n int
+		n, err = r.Read(buf)
+		if n > 0 {
+			if int64(n) > size-offset {
+				err = errors.New("more data than the file size")
+				return
+			}
+			_, err = f.Write(buf[:n])
+			if err != nil {
+				return
+			}
+			offset += int64(n)
+			if time.Since(last) > progressEvery {
+				last = time.Now()
+				log.Infof("%s %d/%d bytes", name, offset, size)
+			}
+		}
+		if err == io.EOF && offset < size {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil && err != io.EOF {
+			return
+		}
+	}
+	err = f.Close()
+	if err != nil {
+		return
+	}
+	elapsed := time.Since(start)
+	log.Infof("%s %d bytes in %s, %.0f bytes/sec", name, offset-resumed, elapsed, float64(offset-resumed)/elapsed.Seconds())
+
+	ok, err := verify(part, hash)
+	if err != nil {
+		return
+	}
+	if !ok {
+		// start over on the next try
+		os.Remove(part)
+		err = errors.New("integrity check failed")
+		return
+	}
+	err = os.Rename(part, out)
+	return
+}
+
+// Read the "OK <size> <sha256>" or "ERR <msg>" reply of the server
+func readHeader(r *bufio.Reader) (size int64, hash string, err error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(line)
+	if len(fields) > 0 && fields[0] == "ERR" {
+		err = remoteError(strings.TrimSpace(strings.TrimPrefix(line, "ERR")))
+		return
+	}
+	if len(fields) != 3 || fields[0] != "OK" {
+		err = fmt.Errorf("bad reply %q", line)
+		return
+	}
+	size, err = strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return
+	}
+	hash = fields[2]
+	return
+}
+
+func verify(path, hash string) (ok bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return
+	}
+	ok = hex.EncodeToString(h.Sum(nil)) == hash
+	return
+}
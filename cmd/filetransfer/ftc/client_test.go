@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Serve data with hash to one request, send the requested offset to offsets
+func serveOnce(t *testing.T, data []byte, hash string) (addr string, offsets chan int64) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsets = make(chan int64, 1)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		offset, _ := strconv.ParseInt(fields[2], 10, 64)
+		offsets <- offset
+		fmt.Fprintf(conn, "OK %d %s\n", len(data), hash)
+		conn.Write(data[offset:])
+	}()
+	addr = ln.Addr().String()
+	return
+}
+
+func TestFetchResume(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ftc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	name = "a"
+	out = filepath.Join(tmp, "a")
+
+	data := []byte("hello world")
+	sum := sha256.Sum256(data)
+	// the first 6 bytes of an interrupted transfer
+	err = ioutil.WriteFile(out+".part", data[:6], 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, offsets := serveOnce(t, data, hex.EncodeToString(sum[:]))
+	err = fetch(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset := <-offsets; offset != 6 {
+		t.Fatalf("expect resume at 6, got %d", offset)
+	}
+	got, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(data) {
+		t.Fatalf("expect %q, got %q", data, got)
+	}
+	if _, err = os.Stat(out + ".part"); !os.IsNotExist(err) {
+		t.Fatalf("expect the part file moved, got %v", err)
+	}
+}
+
+func TestFetchVerify(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ftc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	name = "a"
+	out = filepath.Join(tmp, "a")
+
+	sum := sha256.Sum256([]byte("other"))
+	addr, _ := serveOnce(t, []byte("hello world"), hex.EncodeToString(sum[:]))
+	err = fetch(addr)
+	if err == nil || err.Error() != "integrity check failed" {
+		t.Fatalf("expect integrity check failed, got %v", err)
+	}
+	// the corrupt part is dropped so the next try starts over
+	if _, err = os.Stat(out + ".part"); !os.IsNotExist(err) {
+		t.Fatalf("expect the part file removed, got %v", err)
+	}
+	if _, err = os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("expect no file saved, got %v", err)
+	}
+}
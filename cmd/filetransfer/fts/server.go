@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skycoin/skycoin/src/util/file"
+	"github.com/skycoin/skywire/pkg/app"
+)
+
+const (
+	Version = "1.0.0"
+
+	// max length of a request line
+	maxRequestSize = 4096
+)
+
+var (
+	nodeAddress string
+	serverPort  int
+	// directory of the served files
+	dir string
+	// use fixed seed if true
+	seed bool
+	// path for seed, public key and private key
+	seedPath string
+	// allow node public keys to connect
+	nodeKeys app.NodeKeys
+	// reconnect to the node after this long when disconnected, exit if 0
+	reconnectWait time.Duration
+
+	version bool
+
+	// sha256 of the served files, see fileHash
+	hashes      = make(map[string]hashEntry)
+	hashesMutex sync.Mutex
+)
+
+type hashEntry struct {
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+func parseFlags() {
+	flag.StringVar(&nodeAddress, "node-address", ":5000", "node address to connect")
+	flag.IntVar(&serverPort, "p", 28444, "server port")
+	flag.StringVar(&dir, "dir", filepath.Join(file.UserHome(), ".skywire", "fts", "files"), "directory of the served files")
+	flag.BoolVar(&seed, "seed", true, "use fixed seed to connect if true")
+	flag.StringVar(&seedPath, "seed-path", filepath.Join(file.UserHome(), ".skywire", "fts", "keys.json"), "path to save seed info")
+	flag.Var(&nodeKeys, "node-key", "allow node public keys to connect, any node if none")
+	flag.DurationVar(&reconnectWait, "reconnect-wait", 0, "reconnect to the node after this long when disconnected, exit if 0")
+	flag.BoolVar(&version, "v", false, "print current version")
+	flag.Parse()
+}
+
+func main() {
+	parseFlags()
+	if version {
+		fmt.Println(Version)
+		return
+	}
+
+	osSignal := make(chan os.Signal, 1)
+	signal.Notify(osSignal, os.Interrupt, os.Kill)
+
+	appType := app.Public
+	if len(nodeKeys) > 0 {
+		appType = app.Private
+	}
+	a := app.NewServer(appType, "fts", ":"+strconv.Itoa(serverPort), Version)
+	a.SetAllowNodes(nodeKeys)
+	a.SetReconnect(reconnectWait)
+	ln, err := a.Listen()
+	if err != nil {
+		log.Fatal(err)
+	}
+	go serve(ln)
+
+	if !seed {
+		seedPath = ""
+	} else {
+		if len(seedPath) < 1 {
+			seedPath = filepath.Join(file.UserHome(), ".skywire", "fts", "keys.json")
+		}
+	}
+	err = a.Start(nodeAddress, seedPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	select {
+	case signal := <-osSignal:
+		if signal == os.Interrupt {
+			log.Debugln("exit by signal Interrupt")
+		} else if signal == os.Kill {
+			log.Debugln("exit by signal Kill")
+		}
+	}
+}
+
+func serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Errorf("accept err %v", err)
+			return
+		}
+		go func() {
+			err := handle(conn)
+			if err != nil {
+				log.Errorf("conn %s err %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// Serve one request "GET <name> <offset>\n" with "OK <size> <sha256>\n" and the
+// file from offset, or "ERR <msg>\n"
+func handle(conn net.Conn) (err error) {
+	defer conn.Close()
+	r := bufio.NewReaderSize(io.LimitReader(conn, maxRequestSize), maxRequestSize)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return
+	}
+	f, size, hash, offset, err := open(strings.TrimSpace(line))
+	if err != nil {
+		fmt.Fprintf(conn, "ERR %s\n", err)
+		return
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(conn, "OK %d %s\n", size, hash)
+	if err != nil {
+		return
+	}
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return
+	}
+	n, err := io.Copy(conn, f)
+	log.Infof("sent %s bytes %d-%d", f.Name(), offset, offset+n)
+	return
+}
+
+func open(req string) (f *os.File, size int64, hash string, offset int64, err error) {
+	fields := strings.Fields(req)
+	if len(fields) != 3 || fields[0] != "GET" {
+		err = errors.New("bad request")
+		return
+	}
+	// only files right in dir are served
+	name := filepath.Base(fields[1])
+	if name != fields[1] || name == "." || name == ".." {
+		err = errors.New("bad file name")
+		return
+	}
+	offset, err = strconv.ParseInt(fields[2], 10, 64)
+	if err != nil || offset < 0 {
+		err = errors.New("bad offset")
+		return
+	}
+	path, err := resolve(name)
+	if err != nil {
+		err = errors.New("file not found")
+		return
+	}
+	f, err = os.Open(path)
+	if err != nil {
+		err = errors.New("file not found")
+		return
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+		}
+	}()
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+	// path may have been replaced by a symlink since it was resolved
+	linfo, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() || !os.SameFile(info, linfo) {
+		err = errors.New("file not found")
+		return
+	}
+	size = info.Size()
+	if offset > size {
+		err = errors.New("offset beyond the end of file")
+		return
+	}
+	hash, err = fileHash(path, f, info)
+	return
+}
+
+// Resolve the symlinks of the file name in dir, the file must stay in dir
+func resolve(name string) (path string, err error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return
+	}
+	path, err = filepath.EvalSymlinks(filepath.Join(root, name))
+	if err != nil {
+		return
+	}
+	if filepath.Dir(path) != root {
+		err = fmt.Errorf("%s is outside of %s", name, dir)
+	}
+	return
+}
+
+// Return the hex sha256 of f at path, cached by the size and modification time
+func fileHash(path string, f *os.File, info os.FileInfo) (hash string, err error) {
+	hashesMutex.Lock()
+	e, ok := hashes[path]
+	hashesMutex.Unlock()
+	if ok && e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+		hash = e.hash
+		return
+	}
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return
+	}
+	hash = hex.EncodeToString(h.Sum(nil))
+	hashesMutex.Lock()
+	hashes[path] = hashEntry{size: info.Size(), modTime: info.ModTime(), hash: hash}
+	hashesMutex.Unlock()
+	return
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Serve a temp dir with the file a, return the dir and a path outside of it
+func serveTempDir(t *testing.T) (root, outside string) {
+	root, err := ioutil.TempDir("", "fts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir = filepath.Join(root, "files")
+	err = os.Mkdir(dir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "a"), []byte("hello"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside = filepath.Join(root, "secret")
+	err = ioutil.WriteFile(outside, []byte("secret"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestOpen(t *testing.T) {
+	root, _ := serveTempDir(t)
+	defer os.RemoveAll(root)
+	err := os.Mkdir(filepath.Join(dir, "sub"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte("hello"))
+	f, size, hash, offset, err := open("GET a 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if size != 5 || hash != hex.EncodeToString(sum[:]) || offset != 2 {
+		t.Fatalf("expect size 5 offset 2 and the sha256 of the file, got %d %d %s", size, offset, hash)
+	}
+
+	for _, tc := range []struct {
+		req string
+		err string
+	}{
+		{"GET a", "bad request"},
+		{"PUT a 0", "bad request"},
+		{"GET ../secret 0", "bad file name"},
+		{"GET sub/a 0", "bad file name"},
+		{"GET . 0", "bad file name"},
+		{"GET .. 0", "bad file name"},
+		{"GET a -1", "bad offset"},
+		{"GET a x", "bad offset"},
+		{"GET a 6", "offset beyond the end of file"},
+		{"GET b 0", "file not found"},
+		{"GET sub 0", "file not found"},
+	} {
+		f, _, _, _, err := open(tc.req)
+		if err == nil {
+			f.Close()
+			t.Errorf("%q: expect err %s", tc.req, tc.err)
+			continue
+		}
+		if err.Error() != tc.err {
+			t.Errorf("%q: expect err %s, got %s", tc.req, tc.err, err)
+		}
+	}
+}
+
+func TestResolveSymlink(t *testing.T) {
+	root, outside := serveTempDir(t)
+	defer os.RemoveAll(root)
+	err := os.Symlink(outside, filepath.Join(dir, "escape"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(filepath.Join(dir, "a"), filepath.Join(dir, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resolve("escape")
+	if err == nil {
+		t.Fatal("expect a symlink out of dir rejected")
+	}
+	f, _, _, _, err := open("GET escape 0")
+	if err == nil {
+		f.Close()
+		t.Fatal("expect a symlink out of dir not served")
+	}
+	// a symlink staying in dir is served
+	path, err := resolve("link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "a" {
+		t.Fatalf("expect link resolved to a, got %s", path)
+	}
+}
@@ -19,6 +19,7 @@ var (
 	webDir   string
 	webPort  string
 	seedPath string
+	logJSON  bool
 
 	version bool
 )
@@ -29,6 +30,7 @@ func parseFlags() {
 	flag.StringVar(&webPort, "web-port", ":8000", "monitor web page port")
 	flag.StringVar(&address, "address", ":5998", "address to listen on")
 	flag.StringVar(&seedPath, "seed-path", filepath.Join(file.UserHome(), ".skywire", "discovery", "keys.json"), "path to save seed info")
+	flag.BoolVar(&logJSON, "log-json", false, "log json objects instead of text")
 	flag.BoolVar(&version, "v", false, "print current version")
 	flag.Parse()
 }
@@ -47,6 +49,7 @@ func main() {
 	defer f.Close()
 	f.SetDefaultSeedConfigPath(seedPath)
 	f.SetLoggerLevel(factory.DebugLevel)
+	f.SetLoggerJSON(logJSON)
 	f.SetAppVersion(manager.Version)
 	err := f.Listen(address)
 	log.Debugf("listen on %s", address)
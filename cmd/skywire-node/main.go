@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -9,6 +10,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/skycoin/skycoin/src/util/file"
@@ -19,6 +21,8 @@ import (
 var (
 	config   node.Config
 	confPath string
+	// print the migrated node configs and exit
+	confDryRun bool
 
 	version bool
 )
@@ -26,14 +30,29 @@ var (
 func parseFlags() {
 	flag.StringVar(&config.Address, "address", ":5000", "address to listen on")
 	flag.Var(&config.DiscoveryAddresses, "discovery-address", "addresses of discovery")
+	flag.StringVar(&config.DiscoverySelector, "discovery-selector", "all", "how to choose the discoveries to connect: all, round-robin, latency or priority")
+	flag.Var(&config.DiscoveryPriority, "discovery-priority", "addresses of the discoveries the priority selector connects first, in order")
+	flag.IntVar(&config.MaxDiscoveries, "max-discoveries", 0, "max count of discoveries to connect, 0 means no limit")
 	flag.BoolVar(&config.ConnectManager, "connect-manager", true, "connect to manager if true")
 	flag.StringVar(&config.ManagerAddr, "manager-address", ":5998", "address of node manager")
 	flag.StringVar(&config.ManagerWeb, "manager-web", ":8000", "address of node manager")
 	flag.BoolVar(&config.Seed, "seed", true, "use fixed seed to connect if true")
 	flag.StringVar(&config.SeedPath, "seed-path", filepath.Join(file.UserHome(), ".skywire", "node", "keys.json"), "path to save seed info")
+	flag.BoolVar(&config.Compression, "compression", false, "compress large msgs if the remote supports it")
+	flag.BoolVar(&config.Checksum, "checksum", false, "checksum msgs if the remote supports it")
+	flag.DurationVar(&config.AppTimeout, "app-timeout", 90*time.Second, "close app conns that send nothing, not even a ping, for this long")
+	flag.IntVar(&config.AppMaxMemory, "app-max-memory", 0, "max address space of each app in MB, 0 means no limit, linux only")
+	flag.IntVar(&config.AppMaxFiles, "app-max-files", 0, "max open files of each app, 0 means no limit, linux only")
+	flag.IntVar(&config.AppMaxCPU, "app-max-cpu", 0, "max cpu seconds of each app, 0 means no limit, linux only")
+	flag.StringVar(&config.LocalAddress, "local-address", "", "local ip to bind the connections to discoveries and manager to")
+	flag.StringVar(&config.MetricsAddress, "metrics-address", "", "address to serve prometheus metrics on /metrics, disabled if empty")
+	flag.StringVar(&config.MetricsToken, "metrics-token", "", "token required as bearer token or token arg by /metrics if not empty, env "+node.MetricsTokenEnv+" if not set, which keeps it out of the process args")
+	flag.BoolVar(&config.LogJSON, "log-json", false, "log json objects instead of text")
 	flag.StringVar(&config.WebPort, "web-port", ":6001", "monitor web page port")
 	flag.StringVar(&config.AutoStartPath, "auto-start-path", filepath.Join(file.UserHome(), ".skywire", "node", "autoStart.json"), "path to save launch info")
+	flag.StringVar(&config.AppsPath, "apps-path", filepath.Join(file.UserHome(), ".skywire", "node", "apps"), "directory of the app manifests")
 	flag.StringVar(&confPath, "conf", filepath.Join(file.UserHome(), ".skywire", "node", "conf.json"), "node default config")
+	flag.BoolVar(&confDryRun, "conf-dry-run", false, "print the node configs of -conf migrated to the current version without saving them and exit")
 	flag.BoolVar(&version, "v", false, "print current version")
 	flag.Parse()
 }
@@ -44,6 +63,23 @@ func main() {
 		fmt.Println(node.Version)
 		return
 	}
+	if confDryRun {
+		cfs, migrated, err := node.LoadNodeConfigs(confPath)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		d, err := json.MarshalIndent(cfs, "", "  ")
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		fmt.Println(string(d))
+		if migrated {
+			log.Infof("%s would be migrated to version %d", confPath, node.ConfigVersion)
+		}
+		return
+	}
 
 	osSignal := make(chan os.Signal, 1)
 	signal.Notify(osSignal, os.Interrupt, os.Kill)
@@ -56,12 +92,44 @@ func main() {
 		}
 		n = node.New(config.SeedPath, config.AutoStartPath, config.WebPort)
 	}
-	var err error
+	selector, err := node.NewServerSelector(config.DiscoverySelector, config.MaxDiscoveries, config.DiscoveryPriority)
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	n.SetServerSelector(selector)
+	n.SetLogJSON(config.LogJSON)
+	n.SetCompression(config.Compression)
+	n.SetChecksum(config.Checksum)
+	n.SetAppTimeout(config.AppTimeout)
+	if len(config.LocalAddress) > 0 {
+		dial, err := node.LocalAddrDialer(config.LocalAddress)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		n.SetDialer(dial)
+	}
+	if len(config.MetricsToken) == 0 {
+		config.MetricsToken = os.Getenv(node.MetricsTokenEnv)
+	}
+	// the apps started by the node don't need it
+	os.Unsetenv(node.MetricsTokenEnv)
+	if len(config.MetricsAddress) > 0 {
+		n.ServeMetrics(config.MetricsAddress, config.MetricsToken)
+	}
 	if len(config.DiscoveryAddresses) == 0 {
-		cfs := &node.NodeConfigs{}
-		err = node.LoadConfig(cfs, confPath)
+		cfs, migrated, err := node.LoadNodeConfigs(confPath)
 		if err != nil {
 			log.Error(err)
+			os.Exit(1)
+		}
+		if migrated {
+			log.Infof("migrate %s to version %d", confPath, node.ConfigVersion)
+			err = node.WriteConfig(cfs, confPath)
+			if err != nil {
+				log.Error(err)
+			}
 		}
 		key, err := n.GetNodeKey()
 		if err != nil {
@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	ss "github.com/shadowsocks/shadowsocks-go/shadowsocks"
 	log "github.com/sirupsen/logrus"
@@ -27,6 +28,8 @@ var (
 	seedPath string
 	// allow node public keys to connect
 	nodeKeys app.NodeKeys
+	// reconnect to the node after this long when disconnected, exit if 0
+	reconnectWait time.Duration
 
 	version bool
 )
@@ -37,6 +40,7 @@ func parseFlags() {
 	flag.BoolVar(&seed, "seed", true, "use fixed seed to connect if true")
 	flag.StringVar(&seedPath, "seed-path", filepath.Join(file.UserHome(), ".skywire", "ss", "keys.json"), "path to save seed info")
 	flag.Var(&nodeKeys, "node-key", "allow node public keys to connect")
+	flag.DurationVar(&reconnectWait, "reconnect-wait", 0, "reconnect to the node after this long when disconnected, exit if 0")
 	flag.BoolVar(&version, "v", false, "print current version")
 	flag.Parse()
 }
@@ -58,6 +62,7 @@ func main() {
 	appmain()
 	a := app.NewServer(app.Public, "sockss", ":"+strconv.Itoa(serverPort), Version)
 	a.SetAllowNodes(nodeKeys)
+	a.SetReconnect(reconnectWait)
 
 	if !seed {
 		seedPath = ""
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/skycoin/skycoin/src/util/file"
@@ -24,6 +25,8 @@ var (
 	seedPath string
 	// allow node public keys to connect
 	nodeKeys app.NodeKeys
+	// reconnect to the node after this long when disconnected, exit if 0
+	reconnectWait time.Duration
 
 	version bool
 )
@@ -33,6 +36,7 @@ func parseFlags() {
 	flag.BoolVar(&seed, "seed", true, "use fixed seed to connect if true")
 	flag.StringVar(&seedPath, "seed-path", filepath.Join(file.UserHome(), ".skywire", "sshs", "keys.json"), "path to save seed info")
 	flag.Var(&nodeKeys, "node-key", "allow node public keys to connect")
+	flag.DurationVar(&reconnectWait, "reconnect-wait", 0, "reconnect to the node after this long when disconnected, exit if 0")
 	flag.BoolVar(&version, "v", false, "print current version")
 	flag.Parse()
 }
@@ -49,6 +53,7 @@ func main() {
 
 	a := app.NewServer(app.Private, "sshs", ":22", Version)
 	a.SetAllowNodes(nodeKeys)
+	a.SetReconnect(reconnectWait)
 	if !seed {
 		seedPath = ""
 	} else {
@@ -1,8 +1,12 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/skycoin/skycoin/src/cipher"
@@ -17,7 +21,14 @@ type App struct {
 	allowNodes  NodeKeys
 	Version     string
 
+	// reconnect to the node after this long when the conn is lost, exit if 0
+	reconnectWait time.Duration
+
 	AppConnectionInitCallback func(resp *factory.AppConnResp) *factory.AppFeedback
+
+	// ConnectToContext calls waiting for the conn to an app key
+	dials      map[cipher.PubKey][]chan *factory.AppConnResp
+	dialsMutex sync.Mutex
 }
 
 type NodeKeys []string
@@ -65,6 +76,8 @@ func NewClient(appType Type, service, version string) *App {
 func (app *App) Start(addr, scPath string) error {
 	err := app.net.ConnectWithConfig(addr, &factory.ConnConfig{
 		SeedConfigPath: scPath,
+		Reconnect:      app.reconnectWait > 0,
+		ReconnectWait:  app.reconnectWait,
 		OnConnected: func(connection *factory.Connection) {
 			switch app.appType {
 			case Public:
@@ -76,15 +89,79 @@ func (app *App) Start(addr, scPath string) error {
 			}
 		},
 		OnDisconnected: func(connection *factory.Connection) {
+			if app.reconnectWait > 0 {
+				log.Debugf("reconnect in %s on disconnected", app.reconnectWait)
+				return
+			}
 			log.Debug("exit on disconnected")
 			os.Exit(1)
 		},
 		FindServiceNodesByAttributesCallback: app.FindServiceByAttributesCallback,
-		AppConnectionInitCallback:            app.AppConnectionInitCallback,
+		AppConnectionInitCallback:            app.appConnectionInit,
 	})
+	if err != nil && app.reconnectWait > 0 {
+		log.Errorf("connect to node err %s, reconnect in %s", err, app.reconnectWait)
+		return nil
+	}
 	return err
 }
 
+func (app *App) appConnectionInit(resp *factory.AppConnResp) (fb *factory.AppFeedback) {
+	if app.AppConnectionInitCallback != nil {
+		fb = app.AppConnectionInitCallback(resp)
+	}
+	// other discoveries are still building the conn
+	if resp.Failed && resp.Pending > 0 {
+		return
+	}
+	app.dialsMutex.Lock()
+	for _, c := range app.dials[resp.App] {
+		// resp is pooled, hand out copies
+		r := *resp
+		c <- &r
+	}
+	delete(app.dials, resp.App)
+	app.dialsMutex.Unlock()
+	return
+}
+
+// Listen on the service address of a server app, so the app can be served with
+// the standard library, e.g. http.Serve. Call before Start; with port 0 the
+// service is offered with the port chosen by the system.
+func (app *App) Listen() (ln net.Listener, err error) {
+	ln, err = net.Listen("tcp", app.serviceAddr)
+	if err != nil {
+		return
+	}
+	host, _, err := net.SplitHostPort(app.serviceAddr)
+	if err != nil {
+		ln.Close()
+		return
+	}
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		ln.Close()
+		return
+	}
+	app.serviceAddr = net.JoinHostPort(host, port)
+	return
+}
+
+// Ping the node every period and exit if nothing is read from it for timeout,
+// the node closes the app conn if the app sends nothing, not even a ping, for
+// its app timeout. Call before Start.
+func (app *App) SetHeartbeat(period, timeout time.Duration) {
+	app.net.PingPeriod = period
+	app.net.ReadTimeout = timeout
+}
+
+// Keep the app running when the conn to the node is lost, e.g. on node
+// restarts, and connect again every wait until it succeeds. The service is
+// offered again on every new conn. Start doesn't fail then. Call before Start.
+func (app *App) SetReconnect(wait time.Duration) {
+	app.reconnectWait = wait
+}
+
 func (app *App) FindServiceByAttributesCallback(resp *factory.QueryByAttrsResp) {
 	log.Debugf("findServiceByAttributesCallback resp %#v", resp)
 }
@@ -94,24 +171,78 @@ func (app *App) SetAllowNodes(nodes NodeKeys) {
 }
 
 func (app *App) ConnectTo(nodeKeyHex, appKeyHex, discoveryKeyHex string) (err error) {
-	nodeKey, err := cipher.PubKeyFromHex(nodeKeyHex)
+	nodeKey, appKey, discoveryKey, err := parseConnectKeys(nodeKeyHex, appKeyHex, discoveryKeyHex)
 	if err != nil {
 		return
 	}
-	appKey, err := cipher.PubKeyFromHex(appKeyHex)
+	app.connectTo(nodeKey, appKey, discoveryKey)
+	return
+}
+
+// Connect to the app like ConnectTo and wait for the result, which is passed to
+// AppConnectionInitCallback as well. The result is the first success of any
+// discovery, or a failure once every discovery failed. If ctx is done first
+// only the wait is given up, the node goes on building the conn.
+func (app *App) ConnectToContext(ctx context.Context, nodeKeyHex, appKeyHex, discoveryKeyHex string) (resp *factory.AppConnResp, err error) {
+	nodeKey, appKey, discoveryKey, err := parseConnectKeys(nodeKeyHex, appKeyHex, discoveryKeyHex)
 	if err != nil {
 		return
 	}
+	c := make(chan *factory.AppConnResp, 1)
+	app.dialsMutex.Lock()
+	if app.dials == nil {
+		app.dials = make(map[cipher.PubKey][]chan *factory.AppConnResp)
+	}
+	app.dials[appKey] = append(app.dials[appKey], c)
+	app.dialsMutex.Unlock()
+	app.connectTo(nodeKey, appKey, discoveryKey)
+	select {
+	case resp = <-c:
+		if resp.Failed {
+			err = fmt.Errorf("connect to app %s failed: %s", appKeyHex, resp.Msg.Msg)
+		}
+	case <-ctx.Done():
+		app.cancelDial(appKey, c)
+		err = ctx.Err()
+	}
+	return
+}
+
+func (app *App) cancelDial(appKey cipher.PubKey, c chan *factory.AppConnResp) {
+	app.dialsMutex.Lock()
+	defer app.dialsMutex.Unlock()
+	dials := app.dials[appKey]
+	for i, d := range dials {
+		if d == c {
+			app.dials[appKey] = append(dials[:i], dials[i+1:]...)
+			break
+		}
+	}
+	if len(app.dials[appKey]) < 1 {
+		delete(app.dials, appKey)
+	}
+}
 
-	discoveryKey := cipher.PubKey{}
+func (app *App) connectTo(nodeKey, appKey, discoveryKey cipher.PubKey) {
+	app.net.ForEachConn(func(connection *factory.Connection) {
+		connection.BuildAppConnection(nodeKey, appKey, discoveryKey)
+	})
+}
+
+func parseConnectKeys(nodeKeyHex, appKeyHex, discoveryKeyHex string) (nodeKey, appKey, discoveryKey cipher.PubKey, err error) {
+	nodeKey, err = cipher.PubKeyFromHex(nodeKeyHex)
+	if err != nil {
+		return
+	}
+	appKey, err = cipher.PubKeyFromHex(appKeyHex)
+	if err != nil {
+		return
+	}
 	if len(discoveryKeyHex) != 0 {
 		discoveryKey, err = cipher.PubKeyFromHex(discoveryKeyHex)
 		if err != nil {
 			return
 		}
 	}
-	app.net.ForEachConn(func(connection *factory.Connection) {
-		connection.BuildAppConnection(nodeKey, appKey, discoveryKey)
-	})
 	return
 }
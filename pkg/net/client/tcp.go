@@ -9,6 +9,9 @@ import (
 
 type ClientTCPConn struct {
 	conn.TCPConn
+
+	// period of pings, conn.TCP_PING_TICK_PERIOD seconds if 0
+	pingPeriod time.Duration
 }
 
 func NewClientTCPConn(c net.Conn) *ClientTCPConn {
@@ -20,8 +23,17 @@ func NewClientTCPConn(c net.Conn) *ClientTCPConn {
 	}
 }
 
+// Set the period of pings, call before WriteLoop
+func (c *ClientTCPConn) SetPingPeriod(d time.Duration) {
+	c.pingPeriod = d
+}
+
 func (c *ClientTCPConn) WriteLoop() (err error) {
-	ticker := time.NewTicker(time.Second * conn.TCP_PING_TICK_PERIOD)
+	period := c.pingPeriod
+	if period <= 0 {
+		period = time.Second * conn.TCP_PING_TICK_PERIOD
+	}
+	ticker := time.NewTicker(period)
 	defer func() {
 		ticker.Stop()
 		if err != nil {
@@ -57,7 +57,7 @@ func (c *ClientUDPConn) ReadLoop() (err error) {
 			if err != nil {
 				return err
 			}
-		case msg.TYPE_NORMAL, msg.TYPE_FEC, msg.TYPE_SYN:
+		case msg.TYPE_NORMAL, msg.TYPE_FEC, msg.TYPE_SYN, msg.TYPE_FLATE, msg.TYPE_CRC:
 			err = c.Process(t, m)
 			if err != nil {
 				return err
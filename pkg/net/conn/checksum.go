@@ -0,0 +1,60 @@
+package conn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/skycoin/skywire/pkg/net/msg"
+)
+
+const CHECKSUM_SIZE = 4
+
+var ErrChecksum = errors.New("msg checksum mismatch")
+
+// Wrap the body of a msg of type t into a TYPE_CRC body:
+// the type, the body and the crc32 of both
+func AppendChecksum(t byte, body []byte) (result []byte) {
+	result = make([]byte, 1+len(body)+CHECKSUM_SIZE)
+	result[0] = t
+	copy(result[1:], body)
+	sum := crc32.ChecksumIEEE(result[:1+len(body)])
+	binary.BigEndian.PutUint32(result[1+len(body):], sum)
+	return
+}
+
+// Verify and unwrap a TYPE_CRC body, returning the inner type and body
+func VerifyChecksum(b []byte) (t byte, body []byte, err error) {
+	if len(b) < 1+CHECKSUM_SIZE {
+		err = ErrChecksum
+		return
+	}
+	end := len(b) - CHECKSUM_SIZE
+	if crc32.ChecksumIEEE(b[:end]) != binary.BigEndian.Uint32(b[end:]) {
+		err = ErrChecksum
+		return
+	}
+	t = b[0]
+	body = b[1:end]
+	return
+}
+
+// Decode the body of a msg of type t as it was passed to Write
+func DecodeBody(t byte, body []byte) (result []byte, err error) {
+	if t == msg.TYPE_CRC {
+		t, body, err = VerifyChecksum(body)
+		if err != nil {
+			return
+		}
+	}
+	switch t {
+	case msg.TYPE_SYN, msg.TYPE_NORMAL:
+		result = body
+	case msg.TYPE_FLATE:
+		result, err = Decompress(body)
+	default:
+		err = fmt.Errorf("not implemented inner msg type %d", t)
+	}
+	return
+}
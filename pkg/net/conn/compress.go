@@ -0,0 +1,47 @@
+package conn
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/skycoin/skywire/pkg/net/msg"
+)
+
+// msg bodies larger than this are compressed if compression is enabled
+const COMPRESS_THRESHOLD = 256
+
+// Deflate compress b
+func Compress(b []byte) (result []byte, err error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return
+	}
+	_, err = w.Write(b)
+	if err != nil {
+		return
+	}
+	err = w.Close()
+	if err != nil {
+		return
+	}
+	result = buf.Bytes()
+	return
+}
+
+// Inflate b, failing if the result is larger than a msg may be
+func Decompress(b []byte) (result []byte, err error) {
+	r := flate.NewReader(bytes.NewReader(b))
+	defer r.Close()
+	result, err = ioutil.ReadAll(io.LimitReader(r, msg.MAX_MESSAGE_SIZE+1))
+	if err != nil {
+		return
+	}
+	if len(result) > msg.MAX_MESSAGE_SIZE {
+		err = fmt.Errorf("decompressed msg len > max len(%d)", msg.MAX_MESSAGE_SIZE)
+	}
+	return
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/skycoin/skywire/pkg/net/msg"
 )
 
 var (
@@ -36,6 +37,10 @@ type Connection interface {
 	GetSentBytes() uint64
 	// Get received bytes count
 	GetReceivedBytes() uint64
+	// Get sent msgs count
+	GetSentMsgs() uint64
+	// Get received msgs count
+	GetReceivedMsgs() uint64
 
 	NewPendingChannel() (channel int)
 	DeletePendingChannel(channel int)
@@ -50,6 +55,14 @@ type Connection interface {
 	GetCrypto() *Crypto
 
 	SetStatusToError(err error)
+
+	// Compress msgs written from now on, the remote must have agreed to it
+	EnableCompression()
+	IsCompressionEnabled() bool
+
+	// Append a checksum to msgs written from now on, the remote must have agreed to it
+	EnableChecksum()
+	IsChecksumEnabled() bool
 }
 
 type ConnCommonFields struct {
@@ -62,6 +75,8 @@ type ConnCommonFields struct {
 
 	sentBytes     uint64
 	receivedBytes uint64
+	sentMsgs      uint64
+	receivedMsgs  uint64
 
 	Status int // STATUS_CONNECTING, STATUS_CONNECTED, STATUS_ERROR
 	err    error
@@ -81,6 +96,9 @@ type ConnCommonFields struct {
 
 	directlyHistory      *list.List
 	directlyHistoryMutex sync.Mutex
+
+	compression int32
+	checksum    int32
 }
 
 func NewConnCommonFileds() *ConnCommonFields {
@@ -205,6 +223,22 @@ func (c *ConnCommonFields) AddReceivedBytes(n int) {
 	atomic.AddUint64(&c.receivedBytes, uint64(n))
 }
 
+func (c *ConnCommonFields) GetSentMsgs() uint64 {
+	return atomic.LoadUint64(&c.sentMsgs)
+}
+
+func (c *ConnCommonFields) AddSentMsgs(n int) {
+	atomic.AddUint64(&c.sentMsgs, uint64(n))
+}
+
+func (c *ConnCommonFields) GetReceivedMsgs() uint64 {
+	return atomic.LoadUint64(&c.receivedMsgs)
+}
+
+func (c *ConnCommonFields) AddReceivedMsgs(n int) {
+	atomic.AddUint64(&c.receivedMsgs, uint64(n))
+}
+
 func (c *ConnCommonFields) NewPendingChannel() (channel int) {
 	panic("not implemented")
 }
@@ -217,6 +251,39 @@ func (c *ConnCommonFields) WriteToChannel(channel int, bytes []byte) (err error)
 	panic("not implemented")
 }
 
+func (c *ConnCommonFields) EnableCompression() {
+	atomic.StoreInt32(&c.compression, 1)
+}
+
+func (c *ConnCommonFields) IsCompressionEnabled() bool {
+	return atomic.LoadInt32(&c.compression) == 1
+}
+
+func (c *ConnCommonFields) EnableChecksum() {
+	atomic.StoreInt32(&c.checksum, 1)
+}
+
+func (c *ConnCommonFields) IsChecksumEnabled() bool {
+	return atomic.LoadInt32(&c.checksum) == 1
+}
+
+// Compress and checksum the body b of a normal msg as enabled, return the
+// type and body to send, see DecodeBody
+func (c *ConnCommonFields) EncodeBody(b []byte) (t byte, body []byte) {
+	t, body = msg.TYPE_NORMAL, b
+	if c.IsCompressionEnabled() && len(body) > COMPRESS_THRESHOLD {
+		cb, err := Compress(body)
+		if err == nil && len(cb) < len(body) {
+			t, body = msg.TYPE_FLATE, cb
+		}
+	}
+	if c.IsChecksumEnabled() {
+		body = AppendChecksum(t, body)
+		t = msg.TYPE_CRC
+	}
+	return
+}
+
 func (c *ConnCommonFields) SetCrypto(crypto *Crypto) {
 	c.crypto.Store(crypto)
 	c.cryptoCond.Broadcast()
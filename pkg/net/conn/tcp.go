@@ -18,6 +18,9 @@ const (
 type TCPConn struct {
 	*ConnCommonFields
 	TcpConn net.Conn
+
+	// conn is closed if nothing is read for readTimeout, TCP_READ_TIMEOUT seconds if 0
+	readTimeout int64
 }
 
 func (c *TCPConn) ReadLoop() (err error) {
@@ -45,7 +48,7 @@ func (c *TCPConn) ReadLoop() (err error) {
 			n := msg.PING_MSG_HEADER_END
 			reader.Discard(n)
 			c.AddReceivedBytes(n)
-		case msg.TYPE_SYN, msg.TYPE_NORMAL:
+		case msg.TYPE_SYN, msg.TYPE_NORMAL, msg.TYPE_FLATE, msg.TYPE_CRC:
 			err = c.ReadBytes(reader, header, msg.MSG_HEADER_SIZE)
 			if err != nil {
 				return err
@@ -56,7 +59,12 @@ func (c *TCPConn) ReadLoop() (err error) {
 			if err != nil {
 				return err
 			}
-			c.In <- m.Body
+			body, err := DecodeBody(msg_t, m.Body)
+			if err != nil {
+				return err
+			}
+			c.In <- body
+			c.AddReceivedMsgs(1)
 		default:
 			c.GetContextLogger().Debugf("not implemented msg type %d", t)
 			return fmt.Errorf("not implemented msg type %d", msg_t)
@@ -88,8 +96,18 @@ func (c *TCPConn) WriteLoop() (err error) {
 	}
 }
 
-func getTCPReadDeadline() time.Time {
-	return time.Now().Add(time.Second * TCP_READ_TIMEOUT)
+// Close the conn if nothing, not even a ping or pong, is read for d
+func (c *TCPConn) SetReadTimeout(d time.Duration) {
+	atomic.StoreInt64(&c.readTimeout, int64(d))
+	c.TcpConn.SetReadDeadline(time.Now().Add(d))
+}
+
+func (c *TCPConn) getReadDeadline() time.Time {
+	d := time.Duration(atomic.LoadInt64(&c.readTimeout))
+	if d <= 0 {
+		d = time.Second * TCP_READ_TIMEOUT
+	}
+	return time.Now().Add(d)
 }
 
 func (c *TCPConn) ReadBytes(r io.Reader, buf []byte, min int) (err error) {
@@ -103,19 +121,27 @@ func (c *TCPConn) ReadBytes(r io.Reader, buf []byte, min int) (err error) {
 
 func (c *TCPConn) Write(bytes []byte) error {
 	s := atomic.AddUint32(&c.seq, 1)
-	m := msg.New(msg.TYPE_NORMAL, s, bytes)
+	t, bytes := c.EncodeBody(bytes)
+	m := msg.New(t, s, bytes)
+	c.AddSentMsgs(1)
 	return c.WriteBytes(m.Bytes())
 }
 
 func (c *TCPConn) WriteSyn(bytes []byte) error {
 	s := atomic.AddUint32(&c.seq, 1)
 	m := msg.New(msg.TYPE_SYN, s, bytes)
+	c.AddSentMsgs(1)
 	return c.writeDirectly(m.Bytes())
 }
 
 func (c *TCPConn) writeDirectly(bytes []byte) (err error) {
 	c.WriteMutex.Lock()
 	defer c.WriteMutex.Unlock()
+	return c.write(bytes)
+}
+
+// write all bytes to the tcp conn, the caller must hold WriteMutex
+func (c *TCPConn) write(bytes []byte) (err error) {
 	for index := 0; index != len(bytes); {
 		n, err := c.TcpConn.Write(bytes[index:])
 		if err != nil {
@@ -127,7 +153,12 @@ func (c *TCPConn) writeDirectly(bytes []byte) (err error) {
 	return
 }
 
+// Encrypt and write bytes as one unit. The crypto is a stream cipher, so msgs
+// must reach the tcp conn in the order they were encrypted, and a msg written by
+// one goroutine must not be interleaved with the msg of another.
 func (c *TCPConn) WriteBytes(bytes []byte) (err error) {
+	c.WriteMutex.Lock()
+	defer c.WriteMutex.Unlock()
 	crypto := c.GetCrypto()
 	if crypto != nil {
 		err = crypto.Encrypt(bytes)
@@ -135,7 +166,7 @@ func (c *TCPConn) WriteBytes(bytes []byte) (err error) {
 			return
 		}
 	}
-	err = c.writeDirectly(bytes)
+	err = c.write(bytes)
 	return
 }
 
@@ -144,7 +175,7 @@ func (c *TCPConn) Ping() error {
 }
 
 func (c *TCPConn) UpdateLastTime() {
-	c.TcpConn.SetReadDeadline(getTCPReadDeadline())
+	c.TcpConn.SetReadDeadline(c.getReadDeadline())
 	c.ConnCommonFields.UpdateLastTime()
 }
 
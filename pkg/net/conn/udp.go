@@ -204,6 +204,7 @@ func (c *UDPConn) WriteToChannel(channel int, bytes []byte) (err error) {
 }
 
 func (c *UDPConn) writeToChannel(channel int, bytes []byte, msgt byte) (err error) {
+	c.AddSentMsgs(1)
 	if len(bytes) > MAX_UDP_PACKAGE_SIZE {
 		for i := 0; i < len(bytes)/MAX_UDP_PACKAGE_SIZE; i++ {
 			err = c.addToChannel(channel, bytes[i*MAX_UDP_PACKAGE_SIZE:(i+1)*MAX_UDP_PACKAGE_SIZE], msgt)
@@ -225,6 +226,9 @@ func (c *UDPConn) writeToChannel(channel int, bytes []byte, msgt byte) (err erro
 }
 
 func (c *UDPConn) addToChannel(channel int, bytes []byte, msgt byte) (err error) {
+	if msgt == msg.TYPE_NORMAL {
+		msgt, bytes = c.EncodeBody(bytes)
+	}
 	m := msg.NewUDPWithoutSeq(msgt, bytes)
 	c.addToPendingChannel(channel, m)
 	c.pacingChan <- struct{}{}
@@ -289,7 +293,7 @@ func (c *UDPConn) writePendingMsgs() (err error) {
 			c.GetContextLogger().Debugf("before encrypt out %x", pkgBytes)
 		}
 		switch m.Type {
-		case msg.TYPE_NORMAL:
+		case msg.TYPE_NORMAL, msg.TYPE_FLATE, msg.TYPE_CRC:
 			if tx {
 				crypto := c.GetCrypto()
 				if crypto != nil {
@@ -424,7 +428,7 @@ func (c *UDPConn) processAckInfo(m []byte) (err error) {
 
 func (c *UDPConn) process(t byte, seq uint32, m []byte) (err error) {
 	switch t {
-	case msg.TYPE_SYN, msg.TYPE_NORMAL:
+	case msg.TYPE_SYN, msg.TYPE_NORMAL, msg.TYPE_FLATE, msg.TYPE_CRC:
 		err = c.Ack(seq)
 		if err != nil {
 			return
@@ -446,10 +450,18 @@ func (c *UDPConn) process(t byte, seq uint32, m []byte) (err error) {
 					return
 				}
 			}
+			if m.Type == msg.TYPE_FLATE || m.Type == msg.TYPE_CRC {
+				// the checksum also covers pkgs recovered by fec
+				m.Body, err = DecodeBody(m.Type, m.Body)
+				if err != nil {
+					return
+				}
+			}
 			if c.BeforeRead != nil {
 				c.BeforeRead(m)
 			}
 			c.In <- m.Body
+			c.AddReceivedMsgs(1)
 		}
 	}
 	return
@@ -1,6 +1,14 @@
 package conn
 
-import "testing"
+import (
+	"bytes"
+	"crypto/aes"
+	"net"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skywire/pkg/net/msg"
+)
 
 func TestRtt_Less(t *testing.T) {
 	rs := newRttSampler(4)
@@ -16,3 +24,76 @@ func TestRtt_Less(t *testing.T) {
 	t.Log(rs.push(9))
 	t.Log(rs.push(10))
 }
+
+// Create a udp conn receiving from a peer encrypting with the returned crypto
+func newTestUDPConn(t *testing.T) (c *UDPConn, peer *Crypto) {
+	ln, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = NewUDPConn(ln, ln.LocalAddr().(*net.UDPAddr))
+	pk, sk := cipher.GenerateKeyPair()
+	peerPK, peerSK := cipher.GenerateKeyPair()
+	iv := make([]byte, aes.BlockSize)
+	crypto := NewCrypto(pk, sk)
+	peer = NewCrypto(peerPK, peerSK)
+	for _, cr := range []struct {
+		c      *Crypto
+		target cipher.PubKey
+	}{{crypto, peerPK}, {peer, pk}} {
+		if err = cr.c.SetTargetKey(cr.target); err != nil {
+			t.Fatal(err)
+		}
+		if err = cr.c.Init(iv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.SetCrypto(crypto)
+	return
+}
+
+func TestUDPConnDecodeBody(t *testing.T) {
+	c, peer := newTestUDPConn(t)
+	defer c.Close()
+	sender := NewConnCommonFileds()
+	sender.EnableCompression()
+	sender.EnableChecksum()
+
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	typ, body := sender.EncodeBody(data)
+	if typ != msg.TYPE_CRC {
+		t.Fatalf("expect type %d, got %d", msg.TYPE_CRC, typ)
+	}
+	if inner, _, err := VerifyChecksum(body); err != nil || inner != msg.TYPE_FLATE {
+		t.Fatalf("expect a compressed inner msg, got type %d err %v", inner, err)
+	}
+	peer.Encrypt(body)
+	err := c.process(typ, 1, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-c.GetChanIn(); !bytes.Equal(got, data) {
+		t.Fatal("data mismatch")
+	}
+}
+
+func TestUDPConnRejectsCorruptPkg(t *testing.T) {
+	c, peer := newTestUDPConn(t)
+	defer c.Close()
+	sender := NewConnCommonFileds()
+	sender.EnableChecksum()
+
+	// e.g. a pkg wrongly recovered by fec
+	typ, body := sender.EncodeBody([]byte("hello"))
+	peer.Encrypt(body)
+	body[0] ^= 0xff
+	err := c.process(typ, 1, body)
+	if err != ErrChecksum {
+		t.Fatalf("expect ErrChecksum, got %v", err)
+	}
+	select {
+	case m := <-c.GetChanIn():
+		t.Fatalf("corrupted pkg passed up: %x", m)
+	default:
+	}
+}
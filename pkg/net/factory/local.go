@@ -0,0 +1,99 @@
+package factory
+
+import (
+	"net"
+	"strconv"
+	"sync"
+)
+
+// Plain tcp listeners of this process, keyed by their address. Factories with
+// LocalPipe set connect to them through an in-memory pipe.
+var (
+	localListeners      = make(map[string]*localListener)
+	localListenersMutex sync.RWMutex
+)
+
+type localListener struct {
+	factory *TCPFactory
+	addr    *net.TCPAddr
+}
+
+// Register ln of factory, listeners serving tls or websocket are not, as the
+// pipe would skip their handshakes
+func registerLocalListener(factory *TCPFactory, addr net.Addr) {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || factory.TLSConfig != nil {
+		return
+	}
+	localListenersMutex.Lock()
+	localListeners[tcpAddr.String()] = &localListener{factory: factory, addr: tcpAddr}
+	localListenersMutex.Unlock()
+}
+
+func unregisterLocalListener(factory *TCPFactory) {
+	localListenersMutex.Lock()
+	for k, v := range localListeners {
+		if v.factory == factory {
+			delete(localListeners, k)
+		}
+	}
+	localListenersMutex.Unlock()
+}
+
+// Find the listener of this process serving the plain tcp address
+func findLocalListener(address string) (ln *localListener, ok bool) {
+	if IsWSAddress(address) || IsTLSAddress(address) {
+		return
+	}
+	host, p, err := net.SplitHostPort(address)
+	if err != nil {
+		return
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return
+	}
+	ip := net.ParseIP(host)
+	if len(host) == 0 || host == "localhost" {
+		ip = net.IPv4(127, 0, 0, 1)
+	}
+	if ip == nil {
+		return
+	}
+	localListenersMutex.RLock()
+	defer localListenersMutex.RUnlock()
+	for _, l := range localListeners {
+		if l.addr.Port != port {
+			continue
+		}
+		if l.addr.IP.IsUnspecified() || l.addr.IP.Equal(ip) ||
+			(ip.IsUnspecified() && l.addr.IP.IsLoopback()) {
+			return l, true
+		}
+	}
+	return
+}
+
+var localClientAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+// localConn is one end of an in-memory pipe reporting tcp addresses,
+// so callers can still split host and port of the peer
+type localConn struct {
+	net.Conn
+	local, remote net.Addr
+}
+
+func (c *localConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *localConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// Create a pipe to ln, returning the client end
+func (ln *localListener) connect() net.Conn {
+	server, client := net.Pipe()
+	ln.factory.createConn(&localConn{Conn: server, local: ln.addr, remote: localClientAddr})
+	return &localConn{Conn: client, local: localClientAddr, remote: ln.addr}
+}
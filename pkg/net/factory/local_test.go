@@ -0,0 +1,84 @@
+package factory
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+// Listen on a free localhost port, return the address and the accepted conns
+func listenLocal(t *testing.T, factory *TCPFactory) (addr string, accepted chan *Connection) {
+	accepted = make(chan *Connection, 1)
+	factory.AcceptedCallback = func(conn *Connection) {
+		accepted <- conn
+	}
+	err := factory.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr = factory.listener.Addr().String()
+	return
+}
+
+func acceptedConn(t *testing.T, accepted chan *Connection) *Connection {
+	select {
+	case conn := <-accepted:
+		return conn
+	case <-time.After(2 * time.Second):
+		t.Fatal("no conn accepted")
+	}
+	return nil
+}
+
+func TestLocalPipe(t *testing.T) {
+	server := NewTCPFactory()
+	defer server.Close()
+	addr, accepted := listenLocal(t, server)
+
+	client := NewTCPFactory()
+	client.LocalPipe = true
+	defer client.Close()
+	_, err := client.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remote := acceptedConn(t, accepted).GetRemoteAddr(); remote.String() != localClientAddr.String() {
+		t.Fatalf("expect conn through the pipe, got remote %s", remote)
+	}
+
+	// the pipe is opt-in
+	client = NewTCPFactory()
+	defer client.Close()
+	_, err = client.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remote := acceptedConn(t, accepted).GetRemoteAddr(); remote.String() == localClientAddr.String() {
+		t.Fatal("expect tcp conn without LocalPipe")
+	}
+}
+
+func TestLocalPipeSkipsTLSAndWS(t *testing.T) {
+	server := NewTCPFactory()
+	server.TLSConfig = &tls.Config{}
+	defer server.Close()
+	addr, _ := listenLocal(t, server)
+	if _, ok := findLocalListener(addr); ok {
+		t.Fatal("tls listener must not be piped")
+	}
+
+	server = NewTCPFactory()
+	defer server.Close()
+	addr, _ = listenLocal(t, server)
+	if _, ok := findLocalListener(addr); !ok {
+		t.Fatal("expect plain listener to be found")
+	}
+	for _, a := range []string{TLSScheme + addr, WSScheme + addr} {
+		if _, ok := findLocalListener(a); ok {
+			t.Fatalf("%s must not be piped to a plain listener", a)
+		}
+	}
+	if _, ok := findLocalListener("10.0.0.1:" + addr[len("127.0.0.1:"):]); ok {
+		t.Fatal("other ip must not be piped to a localhost listener")
+	}
+}
@@ -1,8 +1,13 @@
 package factory
 
 import (
+	"crypto/tls"
 	"net"
+	"net/http"
+	"strings"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 
 	"github.com/skycoin/skywire/pkg/net/client"
@@ -10,7 +15,32 @@ import (
 )
 
 type TCPFactory struct {
-	listener *net.TCPListener
+	listener net.Listener
+
+	// custom dialer used by Connect, e.g. a proxy dialer or a dialer bound to
+	// a local address, net.Dial if nil
+	Dial func(network, address string) (net.Conn, error)
+
+	// serve tls (wss for websocket addresses) if set on Listen,
+	// the client config of tls:// and wss:// addresses on Connect
+	TLSConfig *tls.Config
+
+	// check the Origin header of websocket conns accepted by Listen, e.g.
+	// AllowOrigins. All are accepted if nil, the msgs are authenticated by
+	// the key reg and not by the origin of a browser.
+	CheckOrigin func(r *http.Request) bool
+
+	// ping period of conns created by Connect, read timeout of all conns,
+	// the conn defaults if 0
+	PingPeriod  time.Duration
+	ReadTimeout time.Duration
+
+	// read timeout of the conns accepted by Listen, ReadTimeout if 0
+	AcceptedReadTimeout time.Duration
+
+	// connect plain tcp addresses served by a factory of this process through
+	// an in-memory pipe, if Dial is nil
+	LocalPipe bool
 
 	FactoryCommonFields
 }
@@ -19,21 +49,27 @@ func NewTCPFactory() *TCPFactory {
 	return &TCPFactory{FactoryCommonFields: NewFactoryCommonFields()}
 }
 
+// Listen on address, an address prefixed with ws:// accepts websocket connections.
+// Connections are served over tls if TLSConfig is set, a tls:// or wss://
+// address requires it.
 func (factory *TCPFactory) Listen(address string) error {
-	addr, err := net.ResolveTCPAddr("tcp", address)
-	if err != nil {
-		return err
+	if (IsTLSAddress(address) || strings.HasPrefix(address, WSSScheme)) && factory.TLSConfig == nil {
+		return ErrNoTLSConfig
 	}
-	ln, err := net.ListenTCP("tcp", addr)
+	if IsWSAddress(address) {
+		return factory.listenWS(HostPort(address))
+	}
+	ln, err := factory.listen(HostPort(address))
 	if err != nil {
 		return err
 	}
 	factory.fieldsMutex.Lock()
 	factory.listener = ln
 	factory.fieldsMutex.Unlock()
+	registerLocalListener(factory, ln.Addr())
 	go func() {
 		for {
-			c, err := ln.AcceptTCP()
+			c, err := ln.Accept()
 			if err != nil {
 				logrus.Errorf("AcceptTCP err %v", err)
 				return
@@ -44,7 +80,36 @@ func (factory *TCPFactory) Listen(address string) error {
 	return nil
 }
 
+func (factory *TCPFactory) listen(address string) (ln net.Listener, err error) {
+	ln, err = net.Listen("tcp", address)
+	if err != nil {
+		return
+	}
+	if factory.TLSConfig != nil {
+		ln = tls.NewListener(ln, factory.TLSConfig)
+	}
+	return
+}
+
+func (factory *TCPFactory) listenWS(address string) error {
+	ln, err := factory.listen(address)
+	if err != nil {
+		return err
+	}
+	factory.fieldsMutex.Lock()
+	factory.listener = ln
+	factory.fieldsMutex.Unlock()
+	go func() {
+		err := http.Serve(ln, http.HandlerFunc(factory.serveWS))
+		if err != nil {
+			logrus.Errorf("websocket Serve err %v", err)
+		}
+	}()
+	return nil
+}
+
 func (factory *TCPFactory) Close() error {
+	unregisterLocalListener(factory)
 	factory.FactoryCommonFields.Close()
 	factory.fieldsMutex.RLock()
 	defer factory.fieldsMutex.RUnlock()
@@ -54,8 +119,13 @@ func (factory *TCPFactory) Close() error {
 	return factory.listener.Close()
 }
 
-func (factory *TCPFactory) createConn(c *net.TCPConn) *Connection {
+func (factory *TCPFactory) createConn(c net.Conn) *Connection {
 	tcpConn := server.NewServerTCPConn(c)
+	if factory.AcceptedReadTimeout > 0 {
+		tcpConn.SetReadTimeout(factory.AcceptedReadTimeout)
+	} else if factory.ReadTimeout > 0 {
+		tcpConn.SetReadTimeout(factory.ReadTimeout)
+	}
 	tcpConn.SetStatusToConnected()
 	conn := newConnection(tcpConn, factory)
 	conn.SetContextLogger(conn.GetContextLogger().WithField("type", "tcp"))
@@ -64,12 +134,50 @@ func (factory *TCPFactory) createConn(c *net.TCPConn) *Connection {
 	return conn
 }
 
+// Connect to address, an address prefixed with ws:// or wss:// is dialed over websocket,
+// an address prefixed with tls:// over tls.
+// With LocalPipe and without a custom Dial, plain tcp addresses served by a
+// factory of this process are connected through an in-memory pipe.
 func (factory *TCPFactory) Connect(address string) (conn *Connection, err error) {
-	c, err := net.Dial("tcp", address)
-	if err != nil {
-		return
+	var c net.Conn
+	dial := factory.Dial
+	var ln *localListener
+	local := false
+	if factory.LocalPipe && dial == nil {
+		ln, local = findLocalListener(address)
+	}
+	if local {
+		c = ln.connect()
+	} else if IsWSAddress(address) {
+		dialer := *websocket.DefaultDialer
+		dialer.NetDial = dial
+		dialer.TLSClientConfig = factory.TLSConfig
+		var ws *websocket.Conn
+		ws, _, err = dialer.Dial(address, nil)
+		if err != nil {
+			return
+		}
+		c = newWSConn(ws)
+	} else {
+		if dial == nil {
+			dial = net.Dial
+		}
+		c, err = dial("tcp", HostPort(address))
+		if err != nil {
+			return
+		}
+		if IsTLSAddress(address) {
+			c, err = factory.tlsClient(c, HostPort(address))
+			if err != nil {
+				return
+			}
+		}
 	}
 	cn := client.NewClientTCPConn(c)
+	cn.SetPingPeriod(factory.PingPeriod)
+	if factory.ReadTimeout > 0 {
+		cn.SetReadTimeout(factory.ReadTimeout)
+	}
 	cn.SetStatusToConnected()
 	conn = newConnection(cn, factory)
 	conn.SetContextLogger(conn.GetContextLogger().WithField("type", "tcp"))
@@ -0,0 +1,94 @@
+package factory
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const TLSScheme = "tls://"
+
+var ErrNoTLSConfig = errors.New("listening on a tls address without TLSConfig")
+
+// Return true if address should be dialed over tls
+func IsTLSAddress(address string) bool {
+	return strings.HasPrefix(address, TLSScheme)
+}
+
+// Run the tls handshake on c dialed to address
+func (factory *TCPFactory) tlsClient(c net.Conn, address string) (tc *tls.Conn, err error) {
+	config := &tls.Config{}
+	if factory.TLSConfig != nil {
+		config = factory.TLSConfig.Clone()
+	}
+	if len(config.ServerName) == 0 {
+		config.ServerName, _, err = net.SplitHostPort(address)
+		if err != nil {
+			c.Close()
+			return
+		}
+	}
+	tc = tls.Client(c, config)
+	err = tc.Handshake()
+	if err != nil {
+		tc.Close()
+	}
+	return
+}
+
+// certFiles serves the pem encoded cert and key files, loading them again
+// once the cert file changed
+type certFiles struct {
+	certFile, keyFile string
+
+	cert    *tls.Certificate
+	modTime time.Time
+	mutex   sync.Mutex
+}
+
+func (f *certFiles) getCertificate(*tls.ClientHelloInfo) (cert *tls.Certificate, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	info, err := os.Stat(f.certFile)
+	if err != nil {
+		// keep serving the loaded cert while the files are replaced
+		if f.cert != nil {
+			return f.cert, nil
+		}
+		return
+	}
+	if f.cert == nil || !info.ModTime().Equal(f.modTime) {
+		var c tls.Certificate
+		c, err = tls.LoadX509KeyPair(f.certFile, f.keyFile)
+		if err != nil {
+			if f.cert != nil {
+				return f.cert, nil
+			}
+			return
+		}
+		f.cert, f.modTime = &c, info.ModTime()
+	}
+	cert = f.cert
+	return
+}
+
+// Create a server tls config serving the pem encoded cert and key files.
+// They are loaded again once the cert file changed, so a cert renewed by an
+// acme client, e.g. certbot, is served without a restart.
+func LoadTLSConfig(certFile, keyFile string) (config *tls.Config, err error) {
+	if len(certFile) == 0 || len(keyFile) == 0 {
+		err = errors.New("tls needs both a cert and a key file")
+		return
+	}
+	f := &certFiles{certFile: certFile, keyFile: keyFile}
+	_, err = f.getCertificate(nil)
+	if err != nil {
+		return
+	}
+	config = &tls.Config{GetCertificate: f.getCertificate}
+	return
+}
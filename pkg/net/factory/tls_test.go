@@ -0,0 +1,139 @@
+package factory
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Write a self-signed pem encoded cert and key for name to dir
+func writeTestCert(t *testing.T, dir, name string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestListenTLSRequiresTLSConfig(t *testing.T) {
+	for _, addr := range []string{TLSScheme + "127.0.0.1:0", WSSScheme + "127.0.0.1:0"} {
+		f := NewTCPFactory()
+		err := f.Listen(addr)
+		f.Close()
+		if err != ErrNoTLSConfig {
+			t.Fatalf("%s: expect ErrNoTLSConfig, got %v", addr, err)
+		}
+	}
+}
+
+func TestLoadTLSConfigReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = LoadTLSConfig(filepath.Join(dir, "cert.pem"), "")
+	if err == nil {
+		t.Fatal("expect error without a key file")
+	}
+	_, err = LoadTLSConfig(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err == nil {
+		t.Fatal("expect error for missing files")
+	}
+
+	certFile, keyFile := writeTestCert(t, dir, "old")
+	config, err := LoadTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := func() string {
+		cert, err := config.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.Subject.CommonName
+	}
+	if n := name(); n != "old" {
+		t.Fatalf("expect old cert, got %s", n)
+	}
+
+	// renewed, e.g. by an acme client
+	writeTestCert(t, dir, "new")
+	later := time.Now().Add(time.Minute)
+	err = os.Chtimes(certFile, later, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := name(); n != "new" {
+		t.Fatalf("expect renewed cert, got %s", n)
+	}
+
+	// the loaded cert is kept while the files are replaced
+	os.Remove(certFile)
+	if n := name(); n != "new" {
+		t.Fatalf("expect the loaded cert, got %s", n)
+	}
+}
+
+func TestConnectTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config, err := LoadTLSConfig(writeTestCert(t, dir, "server"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewTCPFactory()
+	server.TLSConfig = config
+	defer server.Close()
+	addr, accepted := listenLocal(t, server)
+
+	client := NewTCPFactory()
+	client.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	defer client.Close()
+	_, err = client.Connect(TLSScheme + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acceptedConn(t, accepted)
+}
@@ -25,6 +25,9 @@ type UDPFactory struct {
 
 	stopGC chan struct{}
 
+	// accepted conns without any msg or ping for idleTimeout are closed
+	idleTimeout time.Duration
+
 	BeforeReadOnConn func(m *msg.UDPMessage)
 	BeforeSendOnConn func(m *msg.UDPMessage)
 }
@@ -34,6 +37,7 @@ func NewUDPFactory() *UDPFactory {
 		stopGC:              make(chan struct{}),
 		FactoryCommonFields: NewFactoryCommonFields(),
 		udpConnMap:          make(map[string]*Connection),
+		idleTimeout:         time.Second * conn.UDP_GC_PERIOD,
 	}
 	go udpFactory.GC()
 	return udpFactory
@@ -125,18 +129,38 @@ func (factory *UDPFactory) createConnAfterListen(addr *net.UDPAddr, skipBeforeCa
 	return connection, true
 }
 
+// period of checking accepted conns for idle timeout
+const UDP_GC_CHECK_PERIOD = 10
+
+// Close accepted conns without any msg or ping for d, the remote pings every
+// conn.UDP_PING_TICK_PERIOD seconds
+func (factory *UDPFactory) SetIdleTimeout(d time.Duration) {
+	factory.fieldsMutex.Lock()
+	factory.idleTimeout = d
+	factory.fieldsMutex.Unlock()
+}
+
+func (factory *UDPFactory) GetIdleTimeout() (d time.Duration) {
+	factory.fieldsMutex.RLock()
+	d = factory.idleTimeout
+	factory.fieldsMutex.RUnlock()
+	return
+}
+
 func (factory *UDPFactory) GC() {
-	ticker := time.NewTicker(time.Second * conn.UDP_GC_PERIOD)
+	ticker := time.NewTicker(time.Second * UDP_GC_CHECK_PERIOD)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-factory.stopGC:
 			return
 		case <-ticker.C:
 			nowUnix := time.Now().Unix()
+			idle := int64(factory.GetIdleTimeout() / time.Second)
 			var closed []string
 			factory.udpConnMapMutex.RLock()
 			for k, udp := range factory.udpConnMap {
-				if nowUnix-udp.GetLastTime() >= conn.UDP_GC_PERIOD {
+				if nowUnix-udp.GetLastTime() >= idle {
 					udp.SetStatusToError(errors.New("udp gc timeout"))
 					udp.Close()
 					closed = append(closed, k)
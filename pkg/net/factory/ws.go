@@ -0,0 +1,226 @@
+package factory
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	WSScheme  = "ws://"
+	WSSScheme = "wss://"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// Create a CheckOrigin accepting the origins, and requests without an
+// Origin header as sent by clients that are not browsers
+func AllowOrigins(origins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if len(origin) == 0 {
+			return true
+		}
+		for _, o := range origins {
+			if o == origin {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Return true if address should be served or dialed over websocket
+func IsWSAddress(address string) bool {
+	return strings.HasPrefix(address, WSScheme) || strings.HasPrefix(address, WSSScheme)
+}
+
+// Strip the websocket or tls scheme and path from address, returning host:port
+func HostPort(address string) string {
+	for _, scheme := range []string{WSScheme, WSSScheme, TLSScheme} {
+		if strings.HasPrefix(address, scheme) {
+			address = strings.TrimPrefix(address, scheme)
+			if i := strings.Index(address, "/"); i >= 0 {
+				address = address[:i]
+			}
+			break
+		}
+	}
+	return address
+}
+
+// wsConn adapts a message based websocket connection to the stream based net.Conn,
+// every Write is sent as one binary message. Messages are read by a goroutine
+// and read deadlines are kept here, a timeout of the websocket conn can't be
+// recovered from.
+type wsConn struct {
+	*websocket.Conn
+	// received messages, closed with readErr set when reading fails
+	msgs      chan []byte
+	readErr   error
+	closed    chan struct{}
+	closeOnce sync.Once
+	// rest of the message being read
+	buf       []byte
+	readMutex sync.Mutex
+
+	// read deadline, deadlineSet is closed and replaced whenever it changes
+	deadline      time.Time
+	deadlineSet   chan struct{}
+	deadlineMutex sync.Mutex
+
+	writeMutex sync.Mutex
+}
+
+type wsTimeoutError struct{}
+
+func (wsTimeoutError) Error() string   { return "websocket read timeout" }
+func (wsTimeoutError) Timeout() bool   { return true }
+func (wsTimeoutError) Temporary() bool { return true }
+
+func newWSConn(c *websocket.Conn) *wsConn {
+	conn := &wsConn{
+		Conn:        c,
+		msgs:        make(chan []byte),
+		closed:      make(chan struct{}),
+		deadlineSet: make(chan struct{}),
+	}
+	go conn.readMessages()
+	return conn
+}
+
+func (c *wsConn) readMessages() {
+	var err error
+	defer func() {
+		c.readErr = err
+		close(c.msgs)
+	}()
+	for {
+		var t int
+		var r io.Reader
+		t, r, err = c.NextReader()
+		if err != nil {
+			return
+		}
+		if t != websocket.BinaryMessage {
+			continue
+		}
+		var msg []byte
+		msg, err = ioutil.ReadAll(r)
+		if err != nil {
+			return
+		}
+		if len(msg) == 0 {
+			continue
+		}
+		select {
+		case c.msgs <- msg:
+		case <-c.closed:
+			err = errors.New("websocket conn closed")
+			return
+		}
+	}
+}
+
+func (c *wsConn) Read(b []byte) (n int, err error) {
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+	for len(c.buf) == 0 {
+		c.deadlineMutex.Lock()
+		deadline, deadlineSet := c.deadline, c.deadlineSet
+		c.deadlineMutex.Unlock()
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				err = wsTimeoutError{}
+				return
+			}
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+		select {
+		case msg, ok := <-c.msgs:
+			if ok {
+				c.buf = msg
+			} else {
+				err = c.readErr
+			}
+		case <-timeout:
+			err = wsTimeoutError{}
+		case <-deadlineSet:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return
+		}
+	}
+	n = copy(b, c.buf)
+	c.buf = c.buf[n:]
+	return
+}
+
+func (c *wsConn) Write(b []byte) (n int, err error) {
+	c.writeMutex.Lock()
+	err = c.WriteMessage(websocket.BinaryMessage, b)
+	c.writeMutex.Unlock()
+	if err != nil {
+		return
+	}
+	n = len(b)
+	return
+}
+
+func (c *wsConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return c.Conn.Close()
+}
+
+func (c *wsConn) SetReadDeadline(t time.Time) error {
+	c.deadlineMutex.Lock()
+	c.deadline = t
+	close(c.deadlineSet)
+	c.deadlineSet = make(chan struct{})
+	c.deadlineMutex.Unlock()
+	return nil
+}
+
+func (c *wsConn) SetDeadline(t time.Time) (err error) {
+	err = c.SetReadDeadline(t)
+	if err != nil {
+		return
+	}
+	err = c.SetWriteDeadline(t)
+	return
+}
+
+func (factory *TCPFactory) serveWS(w http.ResponseWriter, r *http.Request) {
+	upgrader := wsUpgrader
+	if factory.CheckOrigin != nil {
+		upgrader.CheckOrigin = factory.CheckOrigin
+	}
+	c, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logrus.Errorf("websocket upgrade err %v", err)
+		return
+	}
+	factory.createConn(newWSConn(c))
+}
@@ -0,0 +1,192 @@
+package factory
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Create a connected pair of websocket net.Conns
+func wsPipe(t *testing.T) (c1, c2 net.Conn, stop func()) {
+	accepted := make(chan net.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		accepted <- newWSConn(c)
+	}))
+	ws, _, err := websocket.DefaultDialer.Dial(WSScheme+strings.TrimPrefix(srv.URL, "http://"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1 = newWSConn(ws)
+	c2 = <-accepted
+	stop = func() {
+		c1.Close()
+		c2.Close()
+		srv.Close()
+	}
+	return
+}
+
+func TestWSConnRoundTrip(t *testing.T) {
+	c1, c2, stop := wsPipe(t)
+	defer stop()
+
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	go func() {
+		if _, err := c1.Write(data); err != nil {
+			t.Error(err)
+		}
+	}()
+	// read with a buffer smaller than the message, spanning several reads
+	var got []byte
+	buf := make([]byte, 256)
+	for len(got) < len(data) {
+		n, err := c2.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("data mismatch")
+	}
+}
+
+func TestWSConnConcurrentWrite(t *testing.T) {
+	c1, c2, stop := wsPipe(t)
+	defer stop()
+
+	const writers, count, size = 4, 100, 64
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(b byte) {
+			defer wg.Done()
+			chunk := bytes.Repeat([]byte{b}, size)
+			for j := 0; j < count; j++ {
+				if _, err := c1.Write(chunk); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(byte('a' + i))
+	}
+	// every Write is one message, so chunks must arrive whole
+	chunk := make([]byte, size)
+	for i := 0; i < writers*count; i++ {
+		if _, err := io.ReadFull(c2, chunk); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(chunk, bytes.Repeat(chunk[:1], size)) {
+			t.Fatalf("interleaved chunk %q", chunk)
+		}
+	}
+	wg.Wait()
+}
+
+func TestWSConnReadDeadline(t *testing.T) {
+	c1, c2, stop := wsPipe(t)
+	defer stop()
+
+	c1.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err := c1.Read(make([]byte, 1))
+	ne, ok := err.(net.Error)
+	if !ok || !ne.Timeout() {
+		t.Fatalf("expect timeout error, got %v", err)
+	}
+
+	// the conn is usable again once the deadline is cleared
+	c1.SetReadDeadline(time.Time{})
+	go func() {
+		if _, err := c2.Write([]byte("ok")); err != nil {
+			t.Error(err)
+		}
+	}()
+	got := make([]byte, 2)
+	if _, err := io.ReadFull(c1, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "ok" {
+		t.Fatalf("expect ok, got %q", got)
+	}
+}
+
+func TestWSConnCloseUnblocksRead(t *testing.T) {
+	c1, c2, stop := wsPipe(t)
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c2.Read(make([]byte, 1))
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	c1.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expect read error after remote close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("read not unblocked by close")
+	}
+}
+
+func TestListenWSSRequiresTLSConfig(t *testing.T) {
+	f := NewTCPFactory()
+	defer f.Close()
+	err := f.Listen(WSSScheme + "127.0.0.1:0")
+	if err != ErrNoTLSConfig {
+		t.Fatalf("expect ErrNoTLSConfig, got %v", err)
+	}
+}
+
+func TestListenWSCheckOrigin(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	f := NewTCPFactory()
+	f.CheckOrigin = AllowOrigins([]string{"https://allowed.example"})
+	f.AcceptedCallback = func(conn *Connection) {}
+	defer f.Close()
+	err = f.Listen(WSScheme + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"https://allowed.example", true},
+		{"https://other.example", false},
+	} {
+		header := http.Header{}
+		if len(c.origin) > 0 {
+			header.Set("Origin", c.origin)
+		}
+		ws, _, err := websocket.DefaultDialer.Dial(WSScheme+addr, header)
+		if (err == nil) != c.ok {
+			t.Errorf("origin %q: expect accepted %t, got err %v", c.origin, c.ok, err)
+		}
+		if ws != nil {
+			ws.Close()
+		}
+	}
+}
@@ -37,6 +37,8 @@ const (
 	TYPE_NORMAL = 0x01
 	TYPE_FEC    = 0x02
 	TYPE_SYN    = 0x03
+	TYPE_FLATE  = 0x04 // normal msg with deflate compressed body
+	TYPE_CRC    = 0x05 // normal or flate msg with crc32 appended
 	TYPE_ACK    = 0x80
 	TYPE_PING   = 0x81
 	TYPE_PONG   = 0x82
@@ -13,7 +13,7 @@ type ServerTCPConn struct {
 	conn.TCPConn
 }
 
-func NewServerTCPConn(c *net.TCPConn) *ServerTCPConn {
+func NewServerTCPConn(c net.Conn) *ServerTCPConn {
 	return &ServerTCPConn{
 		TCPConn: conn.TCPConn{
 			TcpConn:          c,
@@ -54,7 +54,7 @@ func (c *ServerTCPConn) ReadLoop() (err error) {
 			if err != nil {
 				return err
 			}
-		case msg.TYPE_SYN, msg.TYPE_NORMAL:
+		case msg.TYPE_SYN, msg.TYPE_NORMAL, msg.TYPE_FLATE, msg.TYPE_CRC:
 			err = c.ReadBytes(reader, header, msg.MSG_HEADER_SIZE)
 			if err != nil {
 				return err
@@ -65,7 +65,12 @@ func (c *ServerTCPConn) ReadLoop() (err error) {
 			if err != nil {
 				return err
 			}
-			c.In <- m.Body
+			body, err := conn.DecodeBody(msg_t, m.Body)
+			if err != nil {
+				return err
+			}
+			c.In <- body
+			c.AddReceivedMsgs(1)
 		default:
 			c.GetContextLogger().Debugf("not implemented msg type %d", t)
 			return fmt.Errorf("not implemented msg type %d", msg_t)
@@ -101,7 +101,7 @@ func (c *ServerUDPConn) ReadLoop(fn func(c *net.UDPConn, addr *net.UDPAddr) *con
 				cc.GetContextLogger().Debugf("pong")
 				return cc.WriteExt(pkg)
 			})
-		case msg.TYPE_NORMAL, msg.TYPE_FEC, msg.TYPE_SYN:
+		case msg.TYPE_NORMAL, msg.TYPE_FEC, msg.TYPE_SYN, msg.TYPE_FLATE, msg.TYPE_CRC:
 			if conn.DEV {
 				nt = time.Now()
 			}
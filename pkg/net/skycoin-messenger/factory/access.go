@@ -0,0 +1,112 @@
+package factory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+type KeySet map[cipher.PubKey]struct{}
+
+func (s KeySet) has(key cipher.PubKey) (ok bool) {
+	_, ok = s[key]
+	return
+}
+
+// AccessControl restricts which keys may register on the server and which keys
+// they may send msgs or build app connections to.
+// An empty allow set allows every key not in the deny set.
+type AccessControl struct {
+	AllowClients KeySet
+	DenyClients  KeySet
+	AllowTargets KeySet
+	DenyTargets  KeySet
+}
+
+type accessControlFile struct {
+	AllowClients []string `json:"allow_clients"`
+	DenyClients  []string `json:"deny_clients"`
+	AllowTargets []string `json:"allow_targets"`
+	DenyTargets  []string `json:"deny_targets"`
+}
+
+func ReadAccessControl(path string) (ac *AccessControl, err error) {
+	fb, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	f := &accessControlFile{}
+	err = json.Unmarshal(fb, f)
+	if err != nil {
+		return
+	}
+	ac = &AccessControl{}
+	lists := []struct {
+		hexes []string
+		set   *KeySet
+	}{
+		{f.AllowClients, &ac.AllowClients},
+		{f.DenyClients, &ac.DenyClients},
+		{f.AllowTargets, &ac.AllowTargets},
+		{f.DenyTargets, &ac.DenyTargets},
+	}
+	for _, l := range lists {
+		*l.set, err = parseKeySet(l.hexes)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func parseKeySet(hexes []string) (s KeySet, err error) {
+	s = make(KeySet, len(hexes))
+	for _, h := range hexes {
+		var key cipher.PubKey
+		key, err = cipher.PubKeyFromHex(h)
+		if err != nil {
+			err = fmt.Errorf("invalid key %s: %v", h, err)
+			return
+		}
+		s[key] = struct{}{}
+	}
+	return
+}
+
+func allowed(allow, deny KeySet, key cipher.PubKey) bool {
+	if deny.has(key) {
+		return false
+	}
+	return len(allow) == 0 || allow.has(key)
+}
+
+// Check if key may register on the server
+func (ac *AccessControl) AllowClient(key cipher.PubKey) bool {
+	if ac == nil {
+		return true
+	}
+	return allowed(ac.AllowClients, ac.DenyClients, key)
+}
+
+// Check if key may be reached through the server
+func (ac *AccessControl) AllowTarget(key cipher.PubKey) bool {
+	if ac == nil {
+		return true
+	}
+	return allowed(ac.AllowTargets, ac.DenyTargets, key)
+}
+
+func (f *MessengerFactory) SetAccessControl(ac *AccessControl) {
+	f.fieldsMutex.Lock()
+	f.accessControl = ac
+	f.fieldsMutex.Unlock()
+}
+
+func (f *MessengerFactory) GetAccessControl() (ac *AccessControl) {
+	f.fieldsMutex.RLock()
+	ac = f.accessControl
+	f.fieldsMutex.RUnlock()
+	return
+}
@@ -0,0 +1,84 @@
+package factory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestAccessControlAllow(t *testing.T) {
+	a, _ := cipher.GenerateKeyPair()
+	b, _ := cipher.GenerateKeyPair()
+	c, _ := cipher.GenerateKeyPair()
+	for _, tc := range []struct {
+		name  string
+		allow KeySet
+		deny  KeySet
+		key   cipher.PubKey
+		ok    bool
+	}{
+		{"empty", nil, nil, a, true},
+		{"allowed", KeySet{a: {}}, nil, a, true},
+		{"not allowed", KeySet{a: {}}, nil, b, false},
+		{"denied", nil, KeySet{a: {}}, a, false},
+		{"not denied", nil, KeySet{a: {}}, b, true},
+		{"deny overrides allow", KeySet{a: {}, b: {}}, KeySet{a: {}}, a, false},
+		{"allowed and other denied", KeySet{a: {}, b: {}}, KeySet{a: {}}, b, true},
+		{"neither allowed nor denied", KeySet{a: {}}, KeySet{b: {}}, c, false},
+	} {
+		clients := &AccessControl{AllowClients: tc.allow, DenyClients: tc.deny}
+		if ok := clients.AllowClient(tc.key); ok != tc.ok {
+			t.Errorf("%s: expect client allowed %t, got %t", tc.name, tc.ok, ok)
+		}
+		if !clients.AllowTarget(tc.key) {
+			t.Errorf("%s: expect target allowed by the client lists", tc.name)
+		}
+		targets := &AccessControl{AllowTargets: tc.allow, DenyTargets: tc.deny}
+		if ok := targets.AllowTarget(tc.key); ok != tc.ok {
+			t.Errorf("%s: expect target allowed %t, got %t", tc.name, tc.ok, ok)
+		}
+		if !targets.AllowClient(tc.key) {
+			t.Errorf("%s: expect client allowed by the target lists", tc.name)
+		}
+	}
+	var ac *AccessControl
+	if !ac.AllowClient(a) || !ac.AllowTarget(a) {
+		t.Fatal("expect nil access control to allow every key")
+	}
+}
+
+func TestRegDenied(t *testing.T) {
+	server, addr := listenTestServer(t)
+	defer server.Close()
+	key, secKey := cipher.GenerateKeyPair()
+	server.SetAccessControl(&AccessControl{DenyClients: KeySet{key: {}}})
+
+	c := dialTestServer(t, addr)
+	defer c.Close()
+	registered := make(chan struct{})
+	c.onConnected = func(*Connection) {
+		close(registered)
+	}
+	c.SetSecKey(secKey)
+	c.StoreContext(publicKey, key)
+	err := c.writeOPSyn(OP_REG_KEY, &regWithKey{PublicKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the server drops the conn instead of answering the reg
+	timeout := time.After(2 * time.Second)
+	for closed := false; !closed; {
+		select {
+		case <-registered:
+			t.Fatal("denied key registered")
+		case _, ok := <-c.GetChanIn():
+			closed = !ok
+		case <-timeout:
+			t.Fatal("conn of the denied key not closed")
+		}
+	}
+	if _, ok := server.GetConnection(key); ok {
+		t.Fatal("denied key has a conn on the server")
+	}
+}
@@ -37,11 +37,15 @@ type Connection struct {
 	fieldsMutex sync.RWMutex
 
 	in chan []byte
+	// msgs sent by local peers, see deliverLocal
+	localIn chan []byte
 
 	proxyConnections map[uint32]*Connection
 
 	appTransports      map[cipher.PubKey]*Transport
 	appTransportsMutex sync.RWMutex
+	// count of discoveries building a conn to the app key for this app
+	appBuilds map[cipher.PubKey]int
 
 	CreatedByTransport *Transport
 	transportPair      *transportPair
@@ -49,6 +53,9 @@ type Connection struct {
 	connectTime int64
 
 	skipFactoryReg bool
+	// registered, the msgs queued in the mailbox are not yet delivered, only
+	// used by the callbackLoop of the conn
+	mailboxPending bool
 
 	appMessages        []PriorityMsg
 	appMessagesReadCnt int
@@ -89,6 +96,7 @@ func newClientConnection(c *factory.Connection, factory *MessengerFactory) *Conn
 		Connection:       c,
 		factory:          factory,
 		in:               make(chan []byte),
+		localIn:          make(chan []byte, localPeerQueue),
 		proxyConnections: make(map[uint32]*Connection),
 		appTransports:    make(map[cipher.PubKey]*Transport),
 	}
@@ -232,13 +240,23 @@ func (c *Connection) Reg() error {
 
 func (c *Connection) RegWithKey(key cipher.PubKey, context map[string]string) error {
 	c.StoreContext(publicKey, key)
-	return c.writeOPSyn(OP_REG_KEY, &regWithKey{PublicKey: key, Context: context, Version: RegWithKeyAndEncryptionVersion})
+	return c.writeOPSyn(OP_REG_KEY, c.newRegWithKey(key, context))
 }
 
 func (c *Connection) RegWithKeys(key, target cipher.PubKey, context map[string]string) error {
 	c.StoreContext(publicKey, key)
 	c.SetTargetKey(target)
-	return c.writeOPSyn(OP_REG_KEY, &regWithKey{PublicKey: key, Context: context, Version: RegWithKeyAndEncryptionVersion})
+	return c.writeOPSyn(OP_REG_KEY, c.newRegWithKey(key, context))
+}
+
+func (c *Connection) newRegWithKey(key cipher.PubKey, context map[string]string) *regWithKey {
+	return &regWithKey{
+		PublicKey: key,
+		Context:   context,
+		Version:   RegWithKeyAndEncryptionVersion,
+		Compress:  c.factory.Compression && c.IsTCP(),
+		Checksum:  c.factory.Checksum && c.IsTCP(),
+	}
 }
 
 // register services to discovery
@@ -397,8 +415,26 @@ func (c *Connection) BuildAppConnection(node, app, discovery cipher.PubKey) erro
 	return c.writeOP(OP_BUILD_APP_CONN, &appConn{Node: node, App: app, Discovery: discovery})
 }
 
+// Send msg to the key to through the server. With LocalPipe, a registered
+// client of this process with LocalPipe gets it directly, skipping the
+// server, unless it has too many msgs queued.
 func (c *Connection) Send(to cipher.PubKey, msg []byte) error {
-	return c.Write(GenSendMsg(c.GetKey(), to, msg))
+	m := GenSendMsg(c.GetKey(), to, msg)
+	if c.factory.LocalPipe {
+		if peer, ok := findLocalPeer(to); ok && peer.deliverLocal(m) {
+			return nil
+		}
+	}
+	return c.Write(m)
+}
+
+// Send msg to every key of to with one msg to the server, to must not have
+// more than MAX_BROADCAST_KEYS keys. Receivers get it as if it was sent by Send.
+func (c *Connection) Broadcast(to []cipher.PubKey, msg []byte) error {
+	if len(to) > MAX_BROADCAST_KEYS {
+		return ErrTooManyBroadcastKeys
+	}
+	return c.Write(GenBroadcastMsg(to, msg))
 }
 
 func (c *Connection) SendCustom(msg []byte) error {
@@ -458,6 +494,8 @@ OUTER:
 				}
 			}
 
+			c.in <- m
+		case m := <-c.localIn:
 			c.in <- m
 		}
 	}
@@ -468,6 +506,8 @@ OUTER:
 				return
 			}
 			c.in <- m
+		case m := <-c.localIn:
+			c.in <- m
 		}
 	}
 }
@@ -496,7 +536,9 @@ func (c *Connection) Close() {
 	if c.keySet {
 		if !c.skipFactoryReg {
 			c.factory.unregister(c.key, c)
+			c.factory.addTraffic(c.key, c)
 		}
+		c.unregisterLocalPeer(c.key)
 		c.keySet = false
 	}
 	if c.in != nil {
@@ -612,6 +654,37 @@ func (c *Connection) getTransport(key cipher.PubKey) (tr *Transport, ok bool) {
 	return
 }
 
+// Count n more discoveries building a conn to app
+func (c *Connection) addAppBuilds(app cipher.PubKey, n int) {
+	c.appTransportsMutex.Lock()
+	if c.appBuilds == nil {
+		c.appBuilds = make(map[cipher.PubKey]int)
+	}
+	c.appBuilds[app] += n
+	c.appTransportsMutex.Unlock()
+}
+
+// A discovery failed to build the conn to app, return how many still build it
+func (c *Connection) failAppBuild(app cipher.PubKey) (pending int) {
+	c.appTransportsMutex.Lock()
+	pending = c.appBuilds[app] - 1
+	if pending > 0 {
+		c.appBuilds[app] = pending
+	} else {
+		pending = 0
+		delete(c.appBuilds, app)
+	}
+	c.appTransportsMutex.Unlock()
+	return
+}
+
+// The conn to app is built, the other discoveries don't matter anymore
+func (c *Connection) doneAppBuild(app cipher.PubKey) {
+	c.appTransportsMutex.Lock()
+	delete(c.appBuilds, app)
+	c.appTransportsMutex.Unlock()
+}
+
 func (c *Connection) UpdateConnectTime() {
 	atomic.StoreInt64(&c.connectTime, time.Now().Unix())
 }
@@ -16,6 +16,8 @@ import (
 type ConnConfig struct {
 	Reconnect     bool
 	ReconnectWait time.Duration
+	// reconnect only while it returns true, always if nil
+	KeepReconnecting func() bool
 
 	// generate seed, private key and public key for the connection
 	// seed config file path
@@ -28,6 +28,17 @@ const (
 	SEND_MSG_META_END
 )
 
+const (
+	BROADCAST_MSG_COUNT_SIZE = 2
+
+	BROADCAST_MSG_COUNT_BEGIN = MSG_HEADER_END
+	BROADCAST_MSG_COUNT_END   = BROADCAST_MSG_COUNT_BEGIN + BROADCAST_MSG_COUNT_SIZE
+	BROADCAST_MSG_KEYS_BEGIN  = BROADCAST_MSG_COUNT_END
+
+	// max count of keys of one broadcast msg
+	MAX_BROADCAST_KEYS = 1024
+)
+
 const (
 	// request public key for the connection
 	OP_REG = iota
@@ -58,6 +69,9 @@ const (
 	// POW (unused)
 	OP_POW
 
+	// im messages to many keys, fanned out as OP_SEND by the server
+	OP_BROADCAST
+
 	OP_SIZE
 )
 
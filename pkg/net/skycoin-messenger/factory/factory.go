@@ -1,10 +1,12 @@
 package factory
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"sync"
 	"time"
 
@@ -22,12 +24,61 @@ type MessengerFactory struct {
 	regConnections      map[cipher.PubKey]*Connection
 	regConnectionsMutex sync.RWMutex
 
+	traffic      map[cipher.PubKey]Traffic
+	trafficMutex sync.RWMutex
+
 	// will deliver the services data to server if true
 	Proxy bool
 
 	// Log writeOP and writeOPSyn calls
 	LogWriteOps bool
 
+	// Negotiate compression of large msgs on tcp connections
+	Compression bool
+
+	// Negotiate checksums of msgs on tcp connections
+	Checksum bool
+
+	// custom dialer for connecting to servers, net.Dial if nil
+	Dial func(network, address string) (net.Conn, error)
+
+	// close udp conns of nodes without any msg or ping for this long on Listen,
+	// conn.UDP_GC_PERIOD seconds if 0
+	UDPIdleTimeout time.Duration
+
+	// forget the traffic of keys without a connection for this long and of the
+	// least recently seen keys beyond MaxTrafficKeys,
+	// DEFAULT_TRAFFIC_IDLE_TIMEOUT and DEFAULT_MAX_TRAFFIC_KEYS if 0
+	TrafficIdleTimeout time.Duration
+	MaxTrafficKeys     int
+
+	// ping period of tcp connections to servers and read timeout of all tcp
+	// connections, a dead remote is detected after ReadTimeout. The defaults
+	// are conn.TCP_PING_TICK_PERIOD and conn.TCP_READ_TIMEOUT seconds.
+	PingPeriod  time.Duration
+	ReadTimeout time.Duration
+
+	// read timeout of the tcp connections accepted on Listen, ReadTimeout if 0,
+	// the connections to servers keep ReadTimeout
+	AcceptedReadTimeout time.Duration
+
+	// serve tls on Listen, the client tls config of tls:// and wss:// servers on Connect
+	TLSConfig *tls.Config
+
+	// origins browsers may open websocket conns from on Listen, all if empty
+	WSOrigins []string
+
+	// connect plain tcp servers of this process through an in-memory pipe,
+	// and deliver the msgs sent to clients of this process with LocalPipe
+	// directly, skipping the server round-trip and its access control
+	LocalPipe bool
+
+	accessControl *AccessControl
+
+	mailbox *Mailbox
+
+	linkEvents linkEvents
+
 	serviceDiscovery
 
 	defaultSeedConfig *SeedConfig
@@ -49,12 +100,25 @@ type MessengerFactory struct {
 }
 
 func NewMessengerFactory() *MessengerFactory {
-	return &MessengerFactory{regConnections: make(map[cipher.PubKey]*Connection), serviceDiscovery: newServiceDiscovery()}
+	return &MessengerFactory{
+		regConnections:   make(map[cipher.PubKey]*Connection),
+		traffic:          make(map[cipher.PubKey]Traffic),
+		serviceDiscovery: newServiceDiscovery(),
+	}
 }
 
 func (f *MessengerFactory) Listen(address string) (err error) {
 	tcp := factory.NewTCPFactory()
 	tcp.AcceptedCallback = f.acceptedCallback
+	tcp.Dial = f.Dial
+	tcp.TLSConfig = f.TLSConfig
+	if len(f.WSOrigins) > 0 {
+		tcp.CheckOrigin = factory.AllowOrigins(f.WSOrigins)
+	}
+	tcp.LocalPipe = f.LocalPipe
+	tcp.PingPeriod = f.PingPeriod
+	tcp.ReadTimeout = f.ReadTimeout
+	tcp.AcceptedReadTimeout = f.AcceptedReadTimeout
 	f.fieldsMutex.Lock()
 	f.factory = tcp
 	f.fieldsMutex.Unlock()
@@ -67,10 +131,13 @@ func (f *MessengerFactory) Listen(address string) (err error) {
 		udp.BeforeReadOnConn = f.BeforeReadOnConn
 		udp.BeforeSendOnConn = f.BeforeSendOnConn
 		udp.AcceptedCallback = f.acceptedUDPCallback
+		if f.UDPIdleTimeout > 0 {
+			udp.SetIdleTimeout(f.UDPIdleTimeout)
+		}
 		f.fieldsMutex.Lock()
 		f.udp = udp
 		f.fieldsMutex.Unlock()
-		err = udp.Listen(address)
+		err = udp.Listen(factory.HostPort(address))
 	}
 	return
 }
@@ -161,6 +228,10 @@ func (f *MessengerFactory) callbackLoop(conn *Connection) (err error) {
 					return
 				}
 			}
+			if conn.mailboxPending {
+				conn.mailboxPending = false
+				f.deliverMailbox(conn.GetKey(), conn)
+			}
 			putOP(int(opn), op)
 		}
 	}
@@ -213,6 +284,8 @@ func (f *MessengerFactory) register(key cipher.PubKey, connection *Connection) {
 		"pubkey": key.Hex(),
 		"conn":   fmt.Sprintf("%p", connection),
 	}).Debugf("reg")
+	// delivered by callbackLoop after the reg resp
+	connection.mailboxPending = true
 }
 
 // Get accepted connection by key
@@ -308,6 +381,15 @@ func (f *MessengerFactory) GetDefaultSeedConfig() (sc *SeedConfig) {
 	return
 }
 
+// Connect to address with config again after the reconnect wait
+func (f *MessengerFactory) reconnect(address string, config *ConnConfig) {
+	time.Sleep(config.ReconnectWait)
+	if config.KeepReconnecting != nil && !config.KeepReconnecting() {
+		return
+	}
+	f.ConnectWithConfig(address, config)
+}
+
 func (f *MessengerFactory) ConnectWithConfig(address string, config *ConnConfig) (err error) {
 	var conn *Connection
 	defer func() {
@@ -318,31 +400,35 @@ func (f *MessengerFactory) ConnectWithConfig(address string, config *ConnConfig)
 	f.fieldsMutex.Lock()
 	if f.factory == nil {
 		tcpFactory := factory.NewTCPFactory()
+		tcpFactory.Dial = f.Dial
+		tcpFactory.TLSConfig = f.TLSConfig
+		tcpFactory.LocalPipe = f.LocalPipe
+		tcpFactory.PingPeriod = f.PingPeriod
+		tcpFactory.ReadTimeout = f.ReadTimeout
 		f.factory = tcpFactory
 	}
 	c, err := f.factory.Connect(address)
 	f.fieldsMutex.Unlock()
 	if err != nil {
 		if config != nil && config.Reconnect {
-			go func() {
-				time.Sleep(config.ReconnectWait)
-				f.ConnectWithConfig(address, config)
-			}()
+			go f.reconnect(address, config)
 		}
 		return err
 	}
 	conn = newClientConnection(c, f)
 	conn.SetContextLogger(conn.GetContextLogger().WithField("dir", "out"))
+	var onConnected, onDisconnected func(*Connection)
+	if config != nil {
+		onConnected, onDisconnected = config.OnConnected, config.OnDisconnected
+	}
+	conn.onConnected, conn.onDisconnected = f.linkCallbacks(address, onConnected, onDisconnected)
 	if config != nil {
-		conn.onConnected = config.OnConnected
-		conn.onDisconnected = config.OnDisconnected
 		conn.findServiceNodesByKeysCallback = config.FindServiceNodesByKeysCallback
 		conn.findServiceNodesByAttributesCallback = config.FindServiceNodesByAttributesCallback
 		conn.appConnectionInitCallback = config.AppConnectionInitCallback
 		if config.Reconnect {
 			conn.reconnect = func() {
-				time.Sleep(config.ReconnectWait)
-				f.ConnectWithConfig(address, config)
+				f.reconnect(address, config)
 			}
 		}
 		if len(config.Context) > 0 {
@@ -465,7 +551,37 @@ func (f *MessengerFactory) acceptUDPWithConfig(address string, config *ConnConfi
 	return
 }
 
+// Return true if the key of conn was registered again by another connection
+func (f *MessengerFactory) isReplaced(conn *Connection) bool {
+	if !conn.IsKeySet() {
+		return false
+	}
+	c, ok := f.GetConnection(conn.GetKey())
+	return ok && c != conn
+}
+
+// Withdraw the services offered to the servers, so they are removed
+// from the discoveries right away instead of on connection timeout
+func (f *MessengerFactory) withdrawServices() {
+	f.fieldsMutex.RLock()
+	ff := f.factory
+	f.fieldsMutex.RUnlock()
+	if ff == nil {
+		return
+	}
+	f.ForEachConn(func(connection *Connection) {
+		if connection.IsClosed() || connection.GetServices() == nil {
+			return
+		}
+		err := connection.UpdateServices(nil)
+		if err != nil {
+			connection.GetContextLogger().Debugf("withdraw services err %v", err)
+		}
+	})
+}
+
 func (f *MessengerFactory) Close() (err error) {
+	f.withdrawServices()
 	f.fieldsMutex.RLock()
 	defer f.fieldsMutex.RUnlock()
 	if f.factory != nil {
@@ -534,6 +650,11 @@ func (f *MessengerFactory) ResyncToDiscovery(connection *Connection) (err error)
 }
 
 func (f *MessengerFactory) discoveryUnregister(conn *Connection) {
+	if f.isReplaced(conn) {
+		// the services of the key belong to the new connection now
+		conn.setServices(nil)
+		return
+	}
 	if f.Proxy {
 		f.serviceDiscovery.unregister(conn)
 		nodeServices := f.pack()
@@ -576,6 +697,16 @@ func (f *MessengerFactory) SetLoggerLevel(level Level) {
 	log.SetLevel(log.Level(level))
 }
 
+// Log one json object per line instead of text, the fields like ctxId and
+// trace become json keys
+func (f *MessengerFactory) SetLoggerJSON(enable bool) {
+	if enable {
+		log.SetFormatter(&log.JSONFormatter{})
+	} else {
+		log.SetFormatter(&log.TextFormatter{})
+	}
+}
+
 func (f *MessengerFactory) SetAppVersion(v string) {
 	f.fieldsMutex.Lock()
 	f.appVersion = v
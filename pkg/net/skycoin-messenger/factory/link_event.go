@@ -0,0 +1,121 @@
+package factory
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+type LinkEventType int
+
+const (
+	// first connection to a server address
+	LinkConnected LinkEventType = iota
+	// an established connection to a server was closed
+	LinkDisconnected
+	// connected again to a server address that was disconnected before
+	LinkRestored
+)
+
+func (t LinkEventType) String() string {
+	switch t {
+	case LinkConnected:
+		return "connected"
+	case LinkDisconnected:
+		return "disconnected"
+	case LinkRestored:
+		return "restored"
+	}
+	return "unknown"
+}
+
+// LinkEvent reports a change of the connection of a client to a server
+type LinkEvent struct {
+	Type    LinkEventType
+	Address string
+	// key of the client on the link
+	Key  cipher.PubKey
+	Time time.Time
+}
+
+type linkEvents struct {
+	subscribers map[int]func(LinkEvent)
+	nextID      int
+	// addresses with a link that went down and was not restored yet
+	down  map[string]struct{}
+	mutex sync.Mutex
+	// keeps the events in order while calling out without mutex
+	emitMutex sync.Mutex
+}
+
+// Call callback on every link event of the client connections of the factory,
+// until the returned func is called. Callbacks are called in order and must not block.
+func (f *MessengerFactory) SubscribeLinkEvents(callback func(LinkEvent)) (unsubscribe func()) {
+	e := &f.linkEvents
+	e.mutex.Lock()
+	if e.subscribers == nil {
+		e.subscribers = make(map[int]func(LinkEvent))
+	}
+	id := e.nextID
+	e.nextID++
+	e.subscribers[id] = callback
+	e.mutex.Unlock()
+	return func() {
+		e.mutex.Lock()
+		delete(e.subscribers, id)
+		e.mutex.Unlock()
+	}
+}
+
+func (f *MessengerFactory) emitLinkEvent(t LinkEventType, address string, key cipher.PubKey) {
+	e := &f.linkEvents
+	ev := LinkEvent{Address: address, Key: key, Time: time.Now()}
+	e.emitMutex.Lock()
+	defer e.emitMutex.Unlock()
+	e.mutex.Lock()
+	if e.down == nil {
+		e.down = make(map[string]struct{})
+	}
+	ev.Type = t
+	if t == LinkConnected {
+		if _, ok := e.down[address]; ok {
+			delete(e.down, address)
+			ev.Type = LinkRestored
+		}
+	} else {
+		e.down[address] = struct{}{}
+	}
+	// callbacks may subscribe or unsubscribe
+	subscribers := make([]func(LinkEvent), 0, len(e.subscribers))
+	for _, s := range e.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	e.mutex.Unlock()
+	for _, s := range subscribers {
+		s(ev)
+	}
+}
+
+// Wrap the connection callbacks of address to emit link events
+func (f *MessengerFactory) linkCallbacks(address string, onConnected, onDisconnected func(*Connection)) (up, down func(*Connection)) {
+	var connected int32
+	up = func(connection *Connection) {
+		atomic.StoreInt32(&connected, 1)
+		f.emitLinkEvent(LinkConnected, address, connection.GetKey())
+		if onConnected != nil {
+			onConnected(connection)
+		}
+	}
+	// called by Close with the fields of connection locked
+	down = func(connection *Connection) {
+		if atomic.CompareAndSwapInt32(&connected, 1, 0) {
+			f.emitLinkEvent(LinkDisconnected, address, connection.key)
+		}
+		if onDisconnected != nil {
+			onDisconnected(connection)
+		}
+	}
+	return
+}
@@ -0,0 +1,59 @@
+package factory
+
+import (
+	"sync"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// Registered client conns of factories with LocalPipe set, keyed by their
+// key. Send delivers msgs to them directly instead of through the server.
+var (
+	localPeers      = make(map[cipher.PubKey]*Connection)
+	localPeersMutex sync.RWMutex
+)
+
+// the count of msgs from local peers a conn queues for its reader
+const localPeerQueue = 64
+
+// Register the client conn c as the local peer of key if its factory has
+// LocalPipe set
+func (c *Connection) registerLocalPeer(key cipher.PubKey) {
+	if !c.factory.LocalPipe || c.localIn == nil {
+		return
+	}
+	localPeersMutex.Lock()
+	localPeers[key] = c
+	localPeersMutex.Unlock()
+}
+
+func (c *Connection) unregisterLocalPeer(key cipher.PubKey) {
+	localPeersMutex.Lock()
+	if localPeers[key] == c {
+		delete(localPeers, key)
+	}
+	localPeersMutex.Unlock()
+}
+
+func findLocalPeer(key cipher.PubKey) (c *Connection, ok bool) {
+	localPeersMutex.RLock()
+	c, ok = localPeers[key]
+	localPeersMutex.RUnlock()
+	return
+}
+
+// Queue the OP_SEND msg m for the reader of c as if the server forwarded it,
+// false if c is closed or its queue is full
+func (c *Connection) deliverLocal(m []byte) (ok bool) {
+	c.fieldsMutex.RLock()
+	defer c.fieldsMutex.RUnlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.localIn <- m:
+		ok = true
+	default:
+	}
+	return
+}
@@ -0,0 +1,67 @@
+package factory
+
+import (
+	"testing"
+	"time"
+)
+
+// Connect a client of a factory with LocalPipe to addr, return it registered
+func connectLocalPeer(t *testing.T, addr string) (f *MessengerFactory, c *Connection) {
+	f = NewMessengerFactory()
+	f.LocalPipe = true
+	connected := make(chan *Connection, 1)
+	err := f.ConnectWithConfig(addr, &ConnConfig{
+		OnConnected: func(connection *Connection) {
+			connected <- connection
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c = <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("not registered")
+	}
+	return
+}
+
+func TestLocalPeerSend(t *testing.T) {
+	server, addr := listenTestServer(t)
+	defer server.Close()
+	senderFactory, sender := connectLocalPeer(t, addr)
+	defer senderFactory.Close()
+	receiverFactory, receiver := connectLocalPeer(t, addr)
+	defer receiverFactory.Close()
+
+	sent := sender.GetSentMsgs()
+	err := sender.Send(receiver.GetKey(), []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-receiver.GetChanIn():
+		if m[MSG_OP_BEGIN] != OP_SEND || string(m[SEND_MSG_TO_PUBLIC_KEY_END:]) != "hello" {
+			t.Fatalf("expect the sent msg, got %x", m)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("msg not delivered")
+	}
+	if n := sender.GetSentMsgs(); n != sent {
+		t.Fatalf("expect the msg skipping the server, sent %d msgs", n-sent)
+	}
+
+	// a closed peer is not delivered to directly anymore
+	key := receiver.GetKey()
+	receiver.Close()
+	if _, ok := findLocalPeer(key); ok {
+		t.Fatal("expect closed peer unregistered")
+	}
+	err = sender.Send(key, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := sender.GetSentMsgs(); n != sent+1 {
+		t.Fatalf("expect the msg sent to the server, sent %d msgs", n-sent)
+	}
+}
@@ -0,0 +1,172 @@
+package factory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// Mailbox queues small msgs sent to keys that are offline and delivers them
+// when the key registers again.
+type Mailbox struct {
+	// max size of a queued msg, larger msgs are dropped
+	MaxMsgSize int
+	// max count of msgs queued per key, the oldest is dropped on overflow
+	MaxMsgs int
+	// queued msgs older than TTL are dropped
+	TTL time.Duration
+	// max total size of the msgs queued for all keys, new msgs are dropped
+	// once it is reached, no limit if 0
+	MaxBytes int
+
+	boxes      map[cipher.PubKey][]queuedMsg
+	size       int
+	lastExpire time.Time
+	mutex      sync.Mutex
+}
+
+const DEFAULT_MAILBOX_MAX_BYTES = 64 << 20
+
+type queuedMsg struct {
+	data   []byte
+	expire time.Time
+}
+
+func NewMailbox(maxMsgSize, maxMsgs int, ttl time.Duration) *Mailbox {
+	return &Mailbox{
+		MaxMsgSize: maxMsgSize,
+		MaxMsgs:    maxMsgs,
+		TTL:        ttl,
+		MaxBytes:   DEFAULT_MAILBOX_MAX_BYTES,
+		boxes:      make(map[cipher.PubKey][]queuedMsg),
+	}
+}
+
+// Queue the OP_SEND msg m for key, return false if it is too large or the
+// mailbox is full
+func (mb *Mailbox) put(key cipher.PubKey, m []byte) bool {
+	if len(m) > mb.MaxMsgSize || mb.MaxMsgs < 1 {
+		return false
+	}
+	now := time.Now()
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+	if now.Sub(mb.lastExpire) > mb.TTL {
+		mb.expire(now)
+	}
+	box := mb.expireMsgs(mb.boxes[key], now)
+	var evicted []queuedMsg
+	if len(box) >= mb.MaxMsgs {
+		evicted = box[:len(box)-mb.MaxMsgs+1]
+	}
+	// evict only if m is queued then
+	if mb.MaxBytes > 0 && mb.size-queuedSize(evicted)+len(m) > mb.MaxBytes {
+		mb.setBox(key, box)
+		return false
+	}
+	mb.size -= queuedSize(evicted)
+	box = box[len(evicted):]
+	data := make([]byte, len(m))
+	copy(data, m)
+	mb.size += len(data)
+	mb.boxes[key] = append(box, queuedMsg{data: data, expire: now.Add(mb.TTL)})
+	return true
+}
+
+// Remove and return the unexpired msgs queued for key
+func (mb *Mailbox) take(key cipher.PubKey) (result [][]byte) {
+	mb.mutex.Lock()
+	box := mb.expireMsgs(mb.boxes[key], time.Now())
+	mb.size -= queuedSize(box)
+	delete(mb.boxes, key)
+	mb.mutex.Unlock()
+	for _, m := range box {
+		result = append(result, m.data)
+	}
+	return
+}
+
+// Drop the expired msgs of every key, keys that never reconnect would
+// hold their msgs forever otherwise
+func (mb *Mailbox) expire(now time.Time) {
+	mb.lastExpire = now
+	for k, box := range mb.boxes {
+		mb.setBox(k, mb.expireMsgs(box, now))
+	}
+}
+
+func (mb *Mailbox) setBox(key cipher.PubKey, box []queuedMsg) {
+	if len(box) < 1 {
+		delete(mb.boxes, key)
+		return
+	}
+	mb.boxes[key] = box
+}
+
+// Return the count of queued msgs
+func (mb *Mailbox) Len() (n int) {
+	mb.mutex.Lock()
+	for _, box := range mb.boxes {
+		n += len(box)
+	}
+	mb.mutex.Unlock()
+	return
+}
+
+// Return the total size of the queued msgs
+func (mb *Mailbox) Size() (n int) {
+	mb.mutex.Lock()
+	n = mb.size
+	mb.mutex.Unlock()
+	return
+}
+
+// Drop the expired msgs of box, called with mutex locked
+func (mb *Mailbox) expireMsgs(box []queuedMsg, now time.Time) []queuedMsg {
+	i := 0
+	for ; i < len(box); i++ {
+		if box[i].expire.After(now) {
+			break
+		}
+	}
+	mb.size -= queuedSize(box[:i])
+	return box[i:]
+}
+
+func queuedSize(box []queuedMsg) (n int) {
+	for _, m := range box {
+		n += len(m.data)
+	}
+	return
+}
+
+// Queue msgs to offline keys in mb, nil disables queueing
+func (f *MessengerFactory) SetMailbox(mb *Mailbox) {
+	f.fieldsMutex.Lock()
+	f.mailbox = mb
+	f.fieldsMutex.Unlock()
+}
+
+func (f *MessengerFactory) GetMailbox() (mb *Mailbox) {
+	f.fieldsMutex.RLock()
+	mb = f.mailbox
+	f.fieldsMutex.RUnlock()
+	return
+}
+
+// Deliver the msgs queued for key on connection, called after the reg resp
+// is written so the client gets its msgs once it is registered
+func (f *MessengerFactory) deliverMailbox(key cipher.PubKey, connection *Connection) {
+	mb := f.GetMailbox()
+	if mb == nil {
+		return
+	}
+	for _, m := range mb.take(key) {
+		err := connection.Write(m)
+		if err != nil {
+			connection.GetContextLogger().Errorf("deliver queued msg err %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package factory
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skywire/pkg/net/factory"
+)
+
+// Start a messenger server on a free localhost port
+func listenTestServer(t *testing.T) (f *MessengerFactory, addr string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr = ln.Addr().String()
+	ln.Close()
+	f = NewMessengerFactory()
+	f.Proxy = true
+	err = f.Listen(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+// Connect a client conn to addr without reg
+func dialTestServer(t *testing.T, addr string) *Connection {
+	c, err := factory.NewTCPFactory().Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newClientConnection(c, NewMessengerFactory())
+}
+
+func TestMailboxEviction(t *testing.T) {
+	key, _ := cipher.GenerateKeyPair()
+	mb := NewMailbox(16, 2, time.Minute)
+	for _, m := range []string{"a", "b", "c"} {
+		if !mb.put(key, []byte(m)) {
+			t.Fatalf("%s not queued", m)
+		}
+	}
+	// the oldest msg is dropped for the new one
+	if got := mb.take(key); len(got) != 2 || string(got[0]) != "b" || string(got[1]) != "c" {
+		t.Fatalf("expect b c, got %q", got)
+	}
+
+	mb.put(key, []byte("a"))
+	mb.put(key, []byte("b"))
+	// a msg over MaxBytes is dropped without evicting a queued one
+	mb.MaxBytes = 2
+	if mb.put(key, []byte("cd")) {
+		t.Fatal("expect msg over MaxBytes dropped")
+	}
+	if mb.Len() != 2 || mb.Size() != 2 {
+		t.Fatalf("expect 2 msgs of 2 bytes, got %d msgs of %d bytes", mb.Len(), mb.Size())
+	}
+	if mb.put(key, bytes.Repeat([]byte("x"), 17)) {
+		t.Fatal("expect msg over MaxMsgSize dropped")
+	}
+}
+
+func TestMailboxExpire(t *testing.T) {
+	key, _ := cipher.GenerateKeyPair()
+	other, _ := cipher.GenerateKeyPair()
+	mb := NewMailbox(16, 10, 50*time.Millisecond)
+	mb.put(key, []byte("a"))
+	mb.put(other, []byte("b"))
+	time.Sleep(100 * time.Millisecond)
+	if got := mb.take(key); len(got) != 0 {
+		t.Fatalf("expect expired msgs dropped, got %q", got)
+	}
+	// the msgs of other keys expire on the next put
+	mb.put(key, []byte("c"))
+	if mb.Len() != 1 || mb.Size() != 1 {
+		t.Fatalf("expect 1 msg of 1 byte, got %d msgs of %d bytes", mb.Len(), mb.Size())
+	}
+}
+
+func TestMailboxDeliverAfterReg(t *testing.T) {
+	server, addr := listenTestServer(t)
+	defer server.Close()
+	mb := NewMailbox(1024, 10, time.Minute)
+	server.SetMailbox(mb)
+
+	sender := dialTestServer(t, addr)
+	defer sender.Close()
+	err := sender.Reg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sender.WaitForKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, secKey := cipher.GenerateKeyPair()
+	err = sender.Send(key, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// queued while key is offline
+	for i := 0; mb.Len() < 1; i++ {
+		if i == 50 {
+			t.Fatal("msg not queued")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	receiver := dialTestServer(t, addr)
+	defer receiver.Close()
+	registered := make(chan struct{})
+	receiver.onConnected = func(*Connection) {
+		close(registered)
+	}
+	receiver.SetSecKey(secKey)
+	receiver.StoreContext(publicKey, key)
+	err = receiver.writeOPSyn(OP_REG_KEY, &regWithKey{PublicKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the queued msg would block the reg resp if it was written first
+	select {
+	case <-registered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("reg resp not received before the queued msg")
+	}
+	select {
+	case m := <-receiver.GetChanIn():
+		if m[MSG_OP_BEGIN] != OP_SEND || string(m[SEND_MSG_TO_PUBLIC_KEY_END:]) != "hello" {
+			t.Fatalf("expect the queued msg, got %x", m)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("queued msg not delivered")
+	}
+	if mb.Len() != 0 {
+		t.Fatalf("expect empty mailbox, got %d msgs", mb.Len())
+	}
+}
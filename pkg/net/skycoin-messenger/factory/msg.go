@@ -1,6 +1,8 @@
 package factory
 
 import (
+	"encoding/binary"
+
 	"github.com/skycoin/skycoin/src/cipher"
 )
 
@@ -18,3 +20,15 @@ func GenSendMsg(from, to cipher.PubKey, msg []byte) []byte {
 	copy(result[SEND_MSG_TO_PUBLIC_KEY_END:], msg)
 	return result
 }
+
+func GenBroadcastMsg(to []cipher.PubKey, msg []byte) []byte {
+	keysEnd := BROADCAST_MSG_KEYS_BEGIN + len(to)*MSG_PUBLIC_KEY_SIZE
+	result := make([]byte, keysEnd+len(msg))
+	result[MSG_OP_BEGIN] = OP_BROADCAST
+	binary.BigEndian.PutUint16(result[BROADCAST_MSG_COUNT_BEGIN:], uint16(len(to)))
+	for i, k := range to {
+		copy(result[BROADCAST_MSG_KEYS_BEGIN+i*MSG_PUBLIC_KEY_SIZE:], k[:])
+	}
+	copy(result[keysEnd:], msg)
+	return result
+}
@@ -0,0 +1,42 @@
+package factory
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func init() {
+	ops[OP_BROADCAST] = &sync.Pool{
+		New: func() interface{} {
+			return new(broadcast)
+		},
+	}
+}
+
+var ErrTooManyBroadcastKeys = errors.New("too many broadcast keys")
+
+type broadcast struct {
+}
+
+// Fan out the msg as one OP_SEND msg per key
+func (broadcast *broadcast) RawExecute(f *MessengerFactory, conn *Connection, m []byte) (rb []byte, err error) {
+	if !conn.IsKeySet() || len(m) < BROADCAST_MSG_KEYS_BEGIN {
+		return
+	}
+	count := int(binary.BigEndian.Uint16(m[BROADCAST_MSG_COUNT_BEGIN:BROADCAST_MSG_COUNT_END]))
+	keysEnd := BROADCAST_MSG_KEYS_BEGIN + count*MSG_PUBLIC_KEY_SIZE
+	if count > MAX_BROADCAST_KEYS || len(m) < keysEnd {
+		conn.GetContextLogger().Infof("invalid broadcast of %d keys", count)
+		return
+	}
+	from := conn.GetKey()
+	body := m[keysEnd:]
+	for i := BROADCAST_MSG_KEYS_BEGIN; i < keysEnd; i += MSG_PUBLIC_KEY_SIZE {
+		key := cipher.NewPubKey(m[i : i+MSG_PUBLIC_KEY_SIZE])
+		f.forwardSend(conn, key, GenSendMsg(from, key, body))
+	}
+	return
+}
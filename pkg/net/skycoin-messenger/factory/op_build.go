@@ -59,7 +59,7 @@ func init() {
 	}
 	resps[OP_BUILD_APP_CONN_OK] = &sync.Pool{
 		New: func() interface{} {
-			return new(nop)
+			return new(appConnOK)
 		},
 	}
 }
@@ -77,6 +77,7 @@ func (req *appConn) Execute(f *MessengerFactory, conn *Connection) (r resp, err
 	}
 
 	sent := make(map[string]struct{})
+	building := 0
 	f.ForEachConn(func(connection *Connection) {
 		discoveryKey := connection.GetTargetKey()
 		if discoveryKey != req.Discovery && req.Discovery != EMPTY_PUBLIC_KEY {
@@ -94,8 +95,11 @@ func (req *appConn) Execute(f *MessengerFactory, conn *Connection) (r resp, err
 			conn.GetContextLogger().Debugf("transport err %v", err)
 			return
 		}
+		logger := conn.GetContextLogger().WithField("trace", traceID(iv))
 		tr := NewTransport(f, conn, fromNode, req.Node, fromApp, req.App)
+		tr.setTrace(iv)
 		tr.SetOnAcceptedUDPCallback(func(connection *Connection) {
+			setTrace(connection, tr.trace)
 			connection.CreatedByTransport = tr
 			sc := f.GetDefaultSeedConfig()
 			connection.GetContextLogger().Debugf("set crypto sc %v", sc)
@@ -108,10 +112,10 @@ func (req *appConn) Execute(f *MessengerFactory, conn *Connection) (r resp, err
 				connection.GetContextLogger().Debugf("set crypto err %v", err)
 			}
 		})
-		conn.GetContextLogger().Debugf("app conn create transport to %s", connection.GetRemoteAddr().String())
+		logger.Debugf("app conn create transport to %s", connection.GetRemoteAddr().String())
 		c, err := tr.ListenAndConnect(connection.GetRemoteAddr().String(), discoveryKey)
 		if err != nil {
-			conn.GetContextLogger().Debugf("transport err %v", err)
+			logger.Debugf("transport err %v", err)
 			return
 		}
 		nodeConn := &forwardNodeConn{
@@ -124,7 +128,20 @@ func (req *appConn) Execute(f *MessengerFactory, conn *Connection) (r resp, err
 		c.writeOP(OP_FORWARD_NODE_CONN, nodeConn)
 		tr.SetupTimeout()
 		conn.setTransport(discoveryKey, tr)
+		building++
 	})
+	if building < 1 {
+		msg := PriorityMsg{Priority: NotFound, Msg: "No discovery to build the connection", Type: Failed}
+		conn.PutMessage(msg)
+		err = conn.writeOP(OP_BUILD_APP_CONN|RESP_PREFIX, &AppConnResp{
+			Discovery: req.Discovery,
+			App:       req.App,
+			Failed:    true,
+			Msg:       msg,
+		})
+		return
+	}
+	conn.addAppBuilds(req.App, building)
 	return
 }
 
@@ -160,10 +177,16 @@ type AppConnResp struct {
 	Port      int
 	Failed    bool
 	Msg       PriorityMsg
+	// count of discoveries still building the conn after this one failed
+	Pending int `json:",omitempty"`
 }
 
 // run on app
 func (req *AppConnResp) Run(conn *Connection) (err error) {
+	// req is pooled, the omitempty fields must not leak into the next one
+	defer func() {
+		*req = AppConnResp{}
+	}()
 	conn.GetContextLogger().Debugf("recv %#v", req)
 	if conn.appConnectionInitCallback != nil {
 		addr := conn.GetRemoteAddr().String()
@@ -173,6 +196,9 @@ func (req *AppConnResp) Run(conn *Connection) (err error) {
 		}
 		req.Host = host
 		fb := conn.appConnectionInitCallback(req)
+		if fb == nil {
+			return nil
+		}
 		fb.App = req.App
 		fb.Discovery = req.Discovery
 		err = conn.writeOP(OP_APP_FEEDBACK, fb)
@@ -197,6 +223,7 @@ func (req *AppFeedback) Execute(f *MessengerFactory, conn *Connection) (r resp,
 		conn.GetContextLogger().Debugf("AppFeedback tr %x not found", req.App)
 		return
 	}
+	conn.GetContextLogger().WithField("trace", tr.trace).Debugf("AppFeedback app %x failed %t", req.App, req.Failed)
 	tr.StopTimeout()
 	return
 }
@@ -218,6 +245,7 @@ func (req *buildConnResp) Execute(f *MessengerFactory, conn *Connection) (r resp
 	}
 	tr.setUDPConn(conn)
 	tr.connAck()
+	appConn.doneAppBuild(req.App)
 	exists := appConn.setTransportIfNotExists(req.App, tr)
 	if exists {
 		tr.Close()
@@ -264,6 +292,21 @@ type forwardNodeConn struct {
 
 // run on manager, conn is udp conn from node A
 func (req *forwardNodeConn) Execute(f *MessengerFactory, conn *Connection) (r resp, err error) {
+	setTrace(conn, traceID(req.Num))
+	if !f.GetAccessControl().AllowTarget(req.Node) {
+		cause := fmt.Sprintf("Node %x not allowed", req.Node)
+		conn.GetContextLogger().Debugf(cause)
+		err = conn.writeOP(OP_FORWARD_NODE_CONN_RESP|RESP_PREFIX, &forwardNodeConnResp{
+			Node:     req.Node,
+			App:      req.App,
+			FromApp:  req.FromApp,
+			FromNode: req.FromNode,
+			Failed:   true,
+			Msg:      PriorityMsg{Priority: NotAllowed, Msg: cause, Type: Failed},
+			Num:      req.Num,
+		})
+		return
+	}
 	c, ok := f.GetConnection(req.Node)
 	if !ok {
 		cause := fmt.Sprintf("Node %x not exists", req.Node)
@@ -309,17 +352,26 @@ type forwardNodeConnResp struct {
 	Msg      PriorityMsg
 	Address  string
 	Num      []byte
+	// msg features node B offers for the udp conn of the transport
+	Compress bool `json:",omitempty"`
+	Checksum bool `json:",omitempty"`
 }
 
 // run on manager, conn is tcp/udp from node B
 func (req *forwardNodeConnResp) Execute(f *MessengerFactory, conn *Connection) (r resp, err error) {
+	// req is pooled and unmarshal keeps the fields missing in the next msg
+	defer func() {
+		req.Compress, req.Checksum = false, false
+	}()
+	logger := conn.GetContextLogger().WithField("trace", traceID(req.Num))
 	c, ok := f.GetConnection(req.FromNode)
 	if !ok {
-		conn.GetContextLogger().Debugf("node %x not exists", req.FromNode)
+		logger.Debugf("node %x not exists", req.FromNode)
 		return
 	}
 
 	if conn.IsUDP() {
+		setTrace(conn, traceID(req.Num))
 		req.Address = conn.GetRemoteAddr().String()
 		if !req.Failed {
 			p, ok := globalTransportPairManagerInstance.get(req.FromApp, req.FromNode, req.Node, req.App)
@@ -340,8 +392,19 @@ func (req *forwardNodeConnResp) Execute(f *MessengerFactory, conn *Connection) (
 	return
 }
 
+// Agree to the msg features node B offered as far as f supports them, a node
+// not offering any gets none
+func (req *forwardNodeConnResp) agree(f *MessengerFactory) *appConnOK {
+	return &appConnOK{
+		Compress: req.Compress && f.Compression,
+		Checksum: req.Checksum && f.Checksum,
+	}
+}
+
 // run on node A, from manager
 func (req *forwardNodeConnResp) Run(conn *Connection) (err error) {
+	agreed := req.agree(conn.factory)
+	req.Compress, req.Checksum = false, false
 	factory := conn.factory.Parent
 	if factory == nil {
 		factory = conn.factory
@@ -367,12 +430,13 @@ func (req *forwardNodeConnResp) Run(conn *Connection) (err error) {
 			App:       req.App,
 			Failed:    req.Failed,
 			Msg:       req.Msg,
+			Pending:   appConn.failAppBuild(req.App),
 		})
 		tr.Close()
 		return
 	}
 	if len(req.Address) > 0 {
-		e := tr.clientSideConnect(req.Address, conn.factory.GetDefaultSeedConfig(), req.Num)
+		e := tr.clientSideConnect(req.Address, conn.factory.GetDefaultSeedConfig(), req.Num, agreed)
 		if e != nil {
 			conn.GetContextLogger().Debugf("forwardNodeConnResp clientSideConnect %v", e)
 		}
@@ -390,10 +454,11 @@ type buildConn struct {
 }
 
 func (req *buildConn) Run(conn *Connection) (err error) {
+	logger := conn.GetContextLogger().WithField("trace", traceID(req.Num))
 	appConn, ok := conn.factory.GetConnection(req.App)
 	if !ok {
 		cause := fmt.Sprintf("Node %x app %x not exists", req.Node, req.App)
-		conn.GetContextLogger().Debugf(cause)
+		logger.Debugf(cause)
 		err = conn.writeOP(OP_FORWARD_NODE_CONN_RESP, &forwardNodeConnResp{
 			Node:     req.Node,
 			App:      req.App,
@@ -409,7 +474,7 @@ func (req *buildConn) Run(conn *Connection) (err error) {
 	s, ok := appConn.getService(req.App)
 	if !ok {
 		cause := fmt.Sprintf("Node %x app %x not exists", req.Node, req.App)
-		conn.GetContextLogger().Debugf(cause)
+		logger.Debugf(cause)
 		err = conn.writeOP(OP_FORWARD_NODE_CONN_RESP, &forwardNodeConnResp{
 			Node:     req.Node,
 			App:      req.App,
@@ -432,7 +497,7 @@ func (req *buildConn) Run(conn *Connection) (err error) {
 		}
 		if !allow {
 			cause := fmt.Sprintf("Node %x app %x forbid %x", req.Node, req.App, req.FromNode)
-			conn.GetContextLogger().Debugf(cause)
+			logger.Debugf(cause)
 			err = conn.writeOP(OP_FORWARD_NODE_CONN_RESP, &forwardNodeConnResp{
 				Node:     req.Node,
 				App:      req.App,
@@ -447,8 +512,11 @@ func (req *buildConn) Run(conn *Connection) (err error) {
 	}
 
 	tr := NewTransport(conn.factory, appConn, req.FromNode, req.Node, req.FromApp, req.App)
+	tr.setTrace(req.Num)
+	logger.Debugf("build conn from node %x app %x to app %x", req.FromNode, req.FromApp, req.App)
 	connection, err := tr.ListenAndConnect(conn.GetRemoteAddr().String(), conn.GetTargetKey())
 	if err != nil {
+		logger.Debugf("transport err %v", err)
 		return
 	}
 	msg := PriorityMsg{
@@ -469,6 +537,8 @@ func (req *buildConn) Run(conn *Connection) (err error) {
 		FromNode: req.FromNode,
 		Msg:      msg,
 		Num:      req.Num,
+		Compress: conn.factory.Compression,
+		Checksum: conn.factory.Checksum,
 	})
 	if err != nil {
 		return
@@ -502,10 +572,25 @@ func (req *connAck) Run(conn *Connection) (err error) {
 	return
 }
 
-type nop struct {
+// Sent by node A on the udp conn of the transport, the msg features both
+// nodes agreed to. Older nodes send it empty.
+type appConnOK struct {
+	Compress bool `json:",omitempty"`
+	Checksum bool `json:",omitempty"`
+}
+
+func (ok *appConnOK) enable(conn *Connection) {
+	if ok.Compress {
+		conn.EnableCompression()
+	}
+	if ok.Checksum {
+		conn.EnableChecksum()
+	}
 }
 
 // run on node b from node a udp
-func (req *nop) Run(conn *Connection) (err error) {
+func (ok *appConnOK) Run(conn *Connection) (err error) {
+	ok.enable(conn)
+	*ok = appConnOK{}
 	return
 }
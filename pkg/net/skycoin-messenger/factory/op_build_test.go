@@ -0,0 +1,58 @@
+package factory
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAppConnOKNegotiation(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		offer string
+		local bool
+		agree bool
+	}{
+		{"both", `{"Compress":true,"Checksum":true}`, true, true},
+		{"local disabled", `{"Compress":true,"Checksum":true}`, false, false},
+		{"peer disabled", `{}`, true, false},
+		// nodes before the negotiation send neither field
+		{"old peer", `{"Failed":false,"Address":"127.0.0.1:1"}`, true, false},
+	} {
+		f := NewMessengerFactory()
+		f.Compression, f.Checksum = c.local, c.local
+		req := &forwardNodeConnResp{}
+		err := json.Unmarshal([]byte(c.offer), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok := req.agree(f)
+		if ok.Compress != c.agree || ok.Checksum != c.agree {
+			t.Errorf("%s: expect %t, got %+v", c.name, c.agree, ok)
+		}
+	}
+}
+
+func TestAppConnOKFromOldPeer(t *testing.T) {
+	// the resp is pooled, an empty one from an older node must not keep the
+	// features agreed to before
+	ok := &appConnOK{}
+	err := json.Unmarshal([]byte(`{"Compress":true,"Checksum":true}`), ok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, addr := listenTestServer(t)
+	defer server.Close()
+	conn := dialTestServer(t, addr)
+	defer conn.Close()
+	ok.Run(conn)
+	if !conn.IsCompressionEnabled() || !conn.IsChecksumEnabled() {
+		t.Fatal("expect compression and checksum enabled")
+	}
+	err = json.Unmarshal([]byte(`{}`), ok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok.Compress || ok.Checksum {
+		t.Fatalf("expect nothing agreed, got %+v", ok)
+	}
+}
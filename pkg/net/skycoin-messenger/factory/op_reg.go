@@ -4,6 +4,7 @@ import (
 	"crypto/aes"
 	"crypto/rand"
 	"errors"
+	"fmt"
 	"io"
 	"sync"
 
@@ -54,6 +55,10 @@ func (reg *reg) Execute(f *MessengerFactory, conn *Connection) (r resp, err erro
 		return
 	}
 	key, _ := cipher.GenerateKeyPair()
+	if !f.GetAccessControl().AllowClient(key) {
+		err = fmt.Errorf("reg key %s not allowed", key.Hex())
+		return
+	}
 	conn.SetKey(key)
 	conn.SetContextLogger(conn.GetContextLogger().WithField("pubkey", key.Hex()))
 	f.register(key, conn)
@@ -68,6 +73,7 @@ type regResp struct {
 func (resp *regResp) Run(conn *Connection) (err error) {
 	conn.SetKey(resp.PubKey)
 	conn.SetContextLogger(conn.GetContextLogger().WithField("pubkey", resp.PubKey.Hex()))
+	conn.registerLocalPeer(resp.PubKey)
 	return
 }
 
@@ -87,13 +93,24 @@ type regWithKey struct {
 	PublicKey cipher.PubKey
 	Context   map[string]string
 	Version   RegVersion
+	Compress  bool `json:",omitempty"`
+	Checksum  bool `json:",omitempty"`
 }
 
 func (reg *regWithKey) Execute(f *MessengerFactory, conn *Connection) (r resp, err error) {
+	// reg is pooled and unmarshal keeps the fields missing in the next msg,
+	// so the omitempty flags of this reg must not leak into the next one
+	defer func() {
+		*reg = regWithKey{}
+	}()
 	if conn.IsKeySet() {
 		conn.GetContextLogger().WithField("pubkey", conn.key.Hex()).Infof("reg already")
 		return
 	}
+	if !f.GetAccessControl().AllowClient(reg.PublicKey) {
+		err = fmt.Errorf("reg key %s not allowed", reg.PublicKey.Hex())
+		return
+	}
 	for k, v := range reg.Context {
 		conn.StoreContext(k, v)
 	}
@@ -112,6 +129,8 @@ func (reg *regWithKey) Execute(f *MessengerFactory, conn *Connection) (r resp, e
 			PublicKey: sc.publicKey,
 			Version:   reg.Version,
 			Hash:      hash,
+			Compress:  reg.Compress && f.Compression,
+			Checksum:  reg.Checksum && f.Checksum,
 		}
 		if _, err = io.ReadFull(rand.Reader, resp.Num); err != nil {
 			return
@@ -123,11 +142,20 @@ func (reg *regWithKey) Execute(f *MessengerFactory, conn *Connection) (r resp, e
 
 		err = conn.writeOPSyn(OP_REG_KEY|RESP_PREFIX,
 			resp)
+		if err == nil {
+			resp.enable(conn)
+		}
 		return
 	}
 	n := cipher.RandByte(64)
 	conn.StoreContext(randomBytes, n)
-	r = &regWithKeyResp{Num: n}
+	resp := &regWithKeyResp{
+		Num:      n,
+		Compress: reg.Compress && f.Compression,
+		Checksum: reg.Checksum && f.Checksum,
+	}
+	resp.enable(conn)
+	r = resp
 	return
 }
 
@@ -136,9 +164,23 @@ type regWithKeyResp struct {
 	Hash      cipher.SHA256
 	PublicKey cipher.PubKey
 	Version   RegVersion
+	Compress  bool `json:",omitempty"`
+	Checksum  bool `json:",omitempty"`
+}
+
+// Enable the msg features both sides agreed to
+func (resp *regWithKeyResp) enable(conn *Connection) {
+	if resp.Compress {
+		conn.EnableCompression()
+	}
+	if resp.Checksum {
+		conn.EnableChecksum()
+	}
 }
 
 func (resp *regWithKeyResp) Run(conn *Connection) (err error) {
+	resp.enable(conn)
+	resp.Compress, resp.Checksum = false, false
 	if resp.Version == RegWithKeyAndEncryptionVersion {
 		k, ok := conn.context.Load(publicKey)
 		if !ok {
@@ -165,6 +207,7 @@ func (resp *regWithKeyResp) Run(conn *Connection) (err error) {
 			Version: resp.Version,
 		})
 		conn.SetKey(pk)
+		conn.registerLocalPeer(pk)
 		return
 	}
 	sk := conn.GetSecKey()
@@ -22,18 +22,32 @@ func (send *send) RawExecute(f *MessengerFactory, conn *Connection, m []byte) (r
 		return
 	}
 	key := cipher.NewPubKey(m[SEND_MSG_TO_PUBLIC_KEY_BEGIN:SEND_MSG_TO_PUBLIC_KEY_END])
+	f.forwardSend(conn, key, m)
+	return
+}
+
+// Forward the OP_SEND msg m from conn to the connection of key
+func (f *MessengerFactory) forwardSend(conn *Connection, key cipher.PubKey, m []byte) {
+	if !f.GetAccessControl().AllowTarget(key) {
+		conn.GetContextLogger().Infof("Key %s not allowed", key.Hex())
+		return
+	}
 	f.regConnectionsMutex.RLock()
 	c, ok := f.regConnections[key]
 	f.regConnectionsMutex.RUnlock()
 	if !ok {
+		mb := f.GetMailbox()
+		if mb != nil && mb.put(key, m) {
+			conn.GetContextLogger().Debugf("Key %s not found, msg queued", key.Hex())
+			return
+		}
 		conn.GetContextLogger().Infof("Key %s not found", key.Hex())
 		return
 	}
-	err = c.Write(m)
+	err := c.Write(m)
 	if err != nil {
 		conn.GetContextLogger().Errorf("forward to Key %s err %v", key.Hex(), err)
 		c.GetContextLogger().Errorf("write %x err %v", m, err)
 		c.Close()
 	}
-	return
 }
@@ -0,0 +1,27 @@
+package factory
+
+import (
+	"encoding/hex"
+)
+
+// length in bytes of the trace id taken from the iv of an app conn build
+const traceIDSize = 8
+
+// The trace id of an app conn build. Every op of the build carries the random
+// iv created by node A, so the logs of node A, discovery and node B about the
+// same build and its transport share the id.
+func traceID(iv []byte) string {
+	if len(iv) > traceIDSize {
+		iv = iv[:traceIDSize]
+	}
+	return hex.EncodeToString(iv)
+}
+
+// Add the trace id to the logs of conn, conns used by a transport keep it for
+// the forwarded app data too
+func setTrace(conn *Connection, trace string) {
+	if conn == nil || len(trace) == 0 {
+		return
+	}
+	conn.SetContextLogger(conn.GetContextLogger().WithField("trace", trace))
+}
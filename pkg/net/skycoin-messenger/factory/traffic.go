@@ -0,0 +1,179 @@
+package factory
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// Traffic of a key relayed by the server, over all its connections
+type Traffic struct {
+	SentBytes     uint64 `json:"sent_bytes"`
+	ReceivedBytes uint64 `json:"received_bytes"`
+	SentMsgs      uint64 `json:"sent_msgs"`
+	ReceivedMsgs  uint64 `json:"received_msgs"`
+
+	// when the last connection of the key was closed
+	lastSeen time.Time
+}
+
+const (
+	DEFAULT_TRAFFIC_IDLE_TIMEOUT = 24 * time.Hour
+	DEFAULT_MAX_TRAFFIC_KEYS     = 100000
+)
+
+func (t *Traffic) add(c *Connection) {
+	t.SentBytes += c.GetSentBytes()
+	t.ReceivedBytes += c.GetReceivedBytes()
+	t.SentMsgs += c.GetSentMsgs()
+	t.ReceivedMsgs += c.GetReceivedMsgs()
+}
+
+// Account the traffic of a closed connection of key
+func (f *MessengerFactory) addTraffic(key cipher.PubKey, c *Connection) {
+	f.trafficMutex.Lock()
+	t := f.traffic[key]
+	t.add(c)
+	t.lastSeen = time.Now()
+	f.traffic[key] = t
+	if len(f.traffic) > f.maxTrafficKeys() {
+		f.pruneTraffic(t.lastSeen)
+	}
+	f.trafficMutex.Unlock()
+}
+
+func (f *MessengerFactory) maxTrafficKeys() int {
+	if f.MaxTrafficKeys > 0 {
+		return f.MaxTrafficKeys
+	}
+	return DEFAULT_MAX_TRAFFIC_KEYS
+}
+
+// Drop the traffic of idle keys, then of the least recently seen keys until a
+// tenth of MaxTrafficKeys is free, so a flood of new keys is pruned in batches.
+// Called with trafficMutex locked.
+func (f *MessengerFactory) pruneTraffic(now time.Time) {
+	idle := f.TrafficIdleTimeout
+	if idle <= 0 {
+		idle = DEFAULT_TRAFFIC_IDLE_TIMEOUT
+	}
+	for k, t := range f.traffic {
+		if now.Sub(t.lastSeen) > idle {
+			delete(f.traffic, k)
+		}
+	}
+	max := f.maxTrafficKeys()
+	if len(f.traffic) <= max {
+		return
+	}
+	keys := make([]cipher.PubKey, 0, len(f.traffic))
+	for k := range f.traffic {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return f.traffic[keys[i]].lastSeen.Before(f.traffic[keys[j]].lastSeen)
+	})
+	for _, k := range keys[:len(keys)-max+max/10] {
+		delete(f.traffic, k)
+	}
+}
+
+// Get the traffic of key, including its live connection
+func (f *MessengerFactory) GetTraffic(key cipher.PubKey) (t Traffic) {
+	f.trafficMutex.RLock()
+	t = f.traffic[key]
+	f.trafficMutex.RUnlock()
+	c, ok := f.GetConnection(key)
+	if ok {
+		t.add(c)
+	}
+	return
+}
+
+// Get the traffic of every key seen by the server
+func (f *MessengerFactory) GetAllTraffic() (result map[cipher.PubKey]Traffic) {
+	f.trafficMutex.RLock()
+	result = make(map[cipher.PubKey]Traffic, len(f.traffic))
+	for k, v := range f.traffic {
+		result[k] = v
+	}
+	f.trafficMutex.RUnlock()
+	f.ForEachAcceptedConnection(func(key cipher.PubKey, conn *Connection) {
+		t := result[key]
+		t.add(conn)
+		result[key] = t
+	})
+	return
+}
+
+// Write the traffic of all keys as json to path every period, until the returned func is called
+func (f *MessengerFactory) ExportTraffic(path string, period time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				err := f.writeTraffic(path)
+				if err != nil {
+					log.Errorf("export traffic err %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
+
+func (f *MessengerFactory) writeTraffic(path string) (err error) {
+	f.trafficMutex.Lock()
+	f.pruneTraffic(time.Now())
+	f.trafficMutex.Unlock()
+	d, err := json.Marshal(f.getAllTrafficByHex())
+	if err != nil {
+		return
+	}
+	err = ioutil.WriteFile(path, d, 0600)
+	return
+}
+
+func (f *MessengerFactory) getAllTrafficByHex() (m map[string]Traffic) {
+	all := f.GetAllTraffic()
+	m = make(map[string]Traffic, len(all))
+	for k, v := range all {
+		m[k.Hex()] = v
+	}
+	return
+}
+
+// Serve the traffic of the key of the query param key as json, of all keys
+// keyed by their hex if it is empty
+func (f *MessengerFactory) ServeTraffic(w http.ResponseWriter, r *http.Request) {
+	var v interface{}
+	if k := r.FormValue("key"); len(k) > 0 {
+		key, err := cipher.PubKeyFromHex(k)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		v = f.GetTraffic(key)
+	} else {
+		v = f.getAllTrafficByHex()
+	}
+	d, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(d)
+}
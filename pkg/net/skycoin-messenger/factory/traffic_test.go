@@ -0,0 +1,64 @@
+package factory
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestServeTraffic(t *testing.T) {
+	server, addr := listenTestServer(t)
+	defer server.Close()
+	conn := dialTestServer(t, addr)
+	defer conn.Close()
+	err := conn.Reg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.WaitForKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := conn.GetKey()
+	srv := httptest.NewServer(http.HandlerFunc(server.ServeTraffic))
+	defer srv.Close()
+
+	get := func(query string, v interface{}) int {
+		resp, err := http.Get(srv.URL + "/traffic" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode
+	}
+	// the reg msgs of the live conn are counted
+	var one Traffic
+	if code := get("?key="+key.Hex(), &one); code != http.StatusOK {
+		t.Fatalf("expect status ok, got %d", code)
+	}
+	if one.ReceivedMsgs == 0 || one.SentMsgs == 0 {
+		t.Fatalf("expect the reg msgs counted, got %+v", one)
+	}
+	var all map[string]Traffic
+	get("", &all)
+	if all[key.Hex()] != one {
+		t.Fatalf("expect %+v of %s, got %+v", one, key.Hex(), all)
+	}
+	if code := get("?key=invalid", &one); code != http.StatusBadRequest {
+		t.Fatalf("expect bad request, got %d", code)
+	}
+	other, _ := cipher.GenerateKeyPair()
+	get("?key="+other.Hex(), &one)
+	if one != (Traffic{}) {
+		t.Fatalf("expect no traffic of an unknown key, got %+v", one)
+	}
+}
@@ -13,12 +13,17 @@ import (
 	"sync/atomic"
 	"time"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/skycoin/skycoin/src/cipher"
 	cn "github.com/skycoin/skywire/pkg/net/conn"
 )
 
+// the last id given to a transport of this process
+var lastTransportID uint64
+
 type Transport struct {
+	// unique in this process, see GetID
+	id uint64
+
 	creator *MessengerFactory
 	// node
 	factory *MessengerFactory
@@ -35,6 +40,7 @@ type Transport struct {
 
 	conns      map[uint32]net.Conn
 	connsMutex sync.RWMutex
+	connIDs    *connIDAllocator
 
 	timeoutTimer  *time.Timer
 	appConnHolder *Connection
@@ -46,6 +52,9 @@ type Transport struct {
 
 	discoveryConn *Connection
 
+	// trace id of the app conn build, added to the logs of the transport conns
+	trace string
+
 	fieldsMutex sync.RWMutex
 }
 
@@ -78,9 +87,18 @@ func (p *transportPair) close() {
 		return
 	}
 	p.closed = true
+	fromConn, toConn := p.fromConn, p.toConn
 	p.fieldsMutex.Unlock()
 	keys := p.fromApp.Hex() + p.fromNode.Hex() + p.toNode.Hex() + p.toApp.Hex()
 	globalTransportPairManagerInstance.del(keys)
+	// the pair is closed by the conn of one side, close the other side as well,
+	// so the transport of the remaining node does not outlive its peer.
+	// close is called by Connection.Close with the fields of the conn locked.
+	for _, c := range []*Connection{fromConn, toConn} {
+		if c != nil {
+			go c.Close()
+		}
+	}
 }
 
 func (p *transportPair) setFromConn(fromConn *Connection) (err error) {
@@ -185,6 +203,7 @@ func NewTransport(creator *MessengerFactory, appConn *Connection, fromNode, toNo
 		panic("invalid appConn value")
 	}
 	t := &Transport{
+		id:            atomic.AddUint64(&lastTransportID, 1),
 		creator:       creator,
 		appConnHolder: appConn,
 		FromNode:      fromNode,
@@ -194,16 +213,30 @@ func NewTransport(creator *MessengerFactory, appConn *Connection, fromNode, toNo
 		clientSide:    cs,
 		factory:       NewMessengerFactory(),
 		conns:         make(map[uint32]net.Conn),
+		connIDs:       newConnIDAllocator(MAX_TRANSPORT_CONN_IDS),
 	}
 	t.factory.Parent = creator
 	t.factory.SetDefaultSeedConfig(creator.GetDefaultSeedConfig())
+	// offered to the remote node for the udp conn of the transport
+	t.factory.Compression = creator.Compression
+	t.factory.Checksum = creator.Checksum
 	return t
 }
 
+// Id of the transport, unique among the transports of this process
+func (t *Transport) GetID() uint64 {
+	return t.id
+}
+
 func (t *Transport) SetOnAcceptedUDPCallback(fn func(connection *Connection)) {
 	t.factory.OnAcceptedUDPCallback = fn
 }
 
+// Set the trace id of the app conn build from its iv
+func (t *Transport) setTrace(iv []byte) {
+	t.trace = traceID(iv)
+}
+
 func (t *Transport) String() string {
 	return fmt.Sprintf("transport From App%s Node%s To Node%s App%s",
 		t.FromApp.Hex(), t.FromNode.Hex(), t.ToNode.Hex(), t.ToApp.Hex())
@@ -220,13 +253,14 @@ func (t *Transport) ListenAndConnect(address string, key cipher.PubKey) (conn *C
 		TargetKey:           key,
 		SkipBeforeCallbacks: true,
 	})
+	setTrace(conn, t.trace)
 	conn.CreatedByTransport = t
 	t.discoveryConn = conn
 	return
 }
 
-// Connect to node B
-func (t *Transport) clientSideConnect(address string, sc *SeedConfig, iv []byte) (err error) {
+// Connect to node B, ok tells node B which msg features both nodes agreed to
+func (t *Transport) clientSideConnect(address string, sc *SeedConfig, iv []byte, ok *appConnOK) (err error) {
 	t.fieldsMutex.Lock()
 	defer t.fieldsMutex.Unlock()
 	if t.connAcked {
@@ -246,11 +280,15 @@ func (t *Transport) clientSideConnect(address string, sc *SeedConfig, iv []byte)
 		err = errors.New("clientSideConnect acceptUDPWithConfig return nil conn")
 		return
 	}
+	setTrace(conn, t.trace)
 	err = conn.SetCrypto(sc.publicKey, sc.secKey, t.ToNode, iv)
 	if err != nil {
 		return
 	}
-	err = conn.writeOP(OP_BUILD_APP_CONN_OK|RESP_PREFIX, &nop{})
+	err = conn.writeOP(OP_BUILD_APP_CONN_OK|RESP_PREFIX, ok)
+	if err == nil {
+		ok.enable(conn)
+	}
 	return
 }
 
@@ -273,6 +311,7 @@ func (t *Transport) serverSiceConnect(address, appAddress string, sc *SeedConfig
 	if err != nil {
 		return
 	}
+	setTrace(conn, t.trace)
 	conn.CreatedByTransport = t
 	conn.SetKey(t.FromNode)
 	err = conn.SetCrypto(sc.publicKey, sc.secKey, t.FromNode, iv)
@@ -293,14 +332,15 @@ func (t *Transport) serverSiceConnect(address, appAddress string, sc *SeedConfig
 	t.conn = conn
 	t.fieldsMutex.Unlock()
 
-	go t.nodeReadLoop(conn, func(id uint32) net.Conn {
+	go t.nodeReadLoop(conn, func(id uint32, open bool) net.Conn {
 		t.connsMutex.Lock()
 		defer t.connsMutex.Unlock()
 		appConn, ok := t.conns[id]
-		if !ok {
+		// the id of a closed conn is reused by the other side
+		if !ok || (appConn == nil && open) {
 			appConn, err = net.Dial("tcp", appAddress)
 			if err != nil {
-				log.Debugf("app conn dial err %v", err)
+				conn.GetContextLogger().Debugf("app conn dial err %v", err)
 				return nil
 			}
 			t.conns[id] = appConn
@@ -320,7 +360,8 @@ func (t *Transport) getDiscoveryDisconntedChan() <-chan struct{} {
 }
 
 // Read from node, write to app
-func (t *Transport) nodeReadLoop(conn *Connection, getAppConn func(id uint32) net.Conn) {
+// getAppConn is called with open set if the pkg is the empty one announcing a new conn
+func (t *Transport) nodeReadLoop(conn *Connection, getAppConn func(id uint32, open bool) net.Conn) {
 	defer func() {
 		t.Close()
 	}()
@@ -337,11 +378,11 @@ func (t *Transport) nodeReadLoop(conn *Connection, getAppConn func(id uint32) ne
 			}
 			t.downloadBW.add(len(m))
 			id := binary.BigEndian.Uint32(m[PKG_HEADER_ID_BEGIN:PKG_HEADER_ID_END])
-			appConn := getAppConn(id)
+			op := m[PKG_HEADER_OP_BEGIN]
+			appConn := getAppConn(id, op == OP_TRANSPORT && len(m) == PKG_HEADER_END)
 			if appConn == nil {
 				continue
 			}
-			op := m[PKG_HEADER_OP_BEGIN]
 			if op == OP_CLOSE {
 				t.connsMutex.Lock()
 				t.conns[id] = nil
@@ -397,6 +438,7 @@ func (t *Transport) appReadLoop(id uint32, appConn net.Conn, conn *Connection, c
 			}
 			if create {
 				delete(t.conns, id)
+				t.connIDs.release(id)
 			} else {
 				t.conns[id] = nil
 			}
@@ -404,6 +446,7 @@ func (t *Transport) appReadLoop(id uint32, appConn net.Conn, conn *Connection, c
 		}
 		if create {
 			delete(t.conns, id)
+			t.connIDs.release(id)
 		}
 	}()
 	if create {
@@ -412,7 +455,7 @@ func (t *Transport) appReadLoop(id uint32, appConn net.Conn, conn *Connection, c
 	for {
 		n, err := appConn.Read(buf[PKG_HEADER_END:])
 		if err != nil {
-			log.Debugf("app conn read err %v, %d", err, n)
+			conn.GetContextLogger().Debugf("app conn read err %v, %d", err, n)
 			return
 		}
 		pkg := buf[:PKG_HEADER_END+n]
@@ -500,19 +543,23 @@ func (t *Transport) accept() {
 	tConn := t.conn
 	t.fieldsMutex.RUnlock()
 
-	go t.nodeReadLoop(tConn, func(id uint32) net.Conn {
+	go t.nodeReadLoop(tConn, func(id uint32, open bool) net.Conn {
 		t.connsMutex.RLock()
 		conn := t.conns[id]
 		t.connsMutex.RUnlock()
 		return conn
 	})
-	var idSeq uint32
 	for {
 		conn, err := t.appNet.Accept()
 		if err != nil {
 			return
 		}
-		id := atomic.AddUint32(&idSeq, 1)
+		id, err := t.connIDs.alloc()
+		if err != nil {
+			tConn.GetContextLogger().Errorf("accept app conn err %v", err)
+			conn.Close()
+			continue
+		}
 		t.connsMutex.Lock()
 		t.conns[id] = conn
 		t.connsMutex.Unlock()
@@ -675,6 +722,22 @@ func (b *bandwidth) getTotal() (r uint) {
 	return
 }
 
+// Return the count of app conns over the transport and the max count it can carry
+func (t *Transport) GetConnIDUsage() (used, max int) {
+	if t.IsClientSide() {
+		return t.connIDs.usage()
+	}
+	t.connsMutex.RLock()
+	for _, v := range t.conns {
+		if v != nil {
+			used++
+		}
+	}
+	t.connsMutex.RUnlock()
+	max = MAX_TRANSPORT_CONN_IDS
+	return
+}
+
 func (t *Transport) GetUploadBandwidth() uint {
 	return t.uploadBW.get()
 }
@@ -0,0 +1,59 @@
+package factory
+
+import (
+	"errors"
+	"sync"
+)
+
+// max count of app conns multiplexed over one transport
+const MAX_TRANSPORT_CONN_IDS = 1<<16 - 1
+
+var ErrConnIDsExhausted = errors.New("transport conn ids exhausted")
+
+// connIDAllocator hands out the ids of the app conns multiplexed over a transport.
+// Unused ids are handed out first, after that the ids of closed conns are reused
+// starting with the one released earliest, so the remote side has long seen its close.
+type connIDAllocator struct {
+	max  uint32
+	next uint32
+	free []uint32
+	used int
+	sync.Mutex
+}
+
+func newConnIDAllocator(max uint32) *connIDAllocator {
+	return &connIDAllocator{max: max}
+}
+
+func (a *connIDAllocator) alloc() (id uint32, err error) {
+	a.Lock()
+	defer a.Unlock()
+	if a.next < a.max {
+		a.next++
+		id = a.next
+	} else if len(a.free) > 0 {
+		id = a.free[0]
+		a.free = a.free[1:]
+	} else {
+		err = ErrConnIDsExhausted
+		return
+	}
+	a.used++
+	return
+}
+
+func (a *connIDAllocator) release(id uint32) {
+	a.Lock()
+	a.free = append(a.free, id)
+	a.used--
+	a.Unlock()
+}
+
+// Return the count of ids in use and the size of the id space
+func (a *connIDAllocator) usage() (used, max int) {
+	a.Lock()
+	used = a.used
+	max = int(a.max)
+	a.Unlock()
+	return
+}
@@ -2,23 +2,61 @@ package main
 
 import (
 	"flag"
+	"net/http"
 	"os"
 	"os/signal"
 
 	"path/filepath"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/skycoin/skycoin/src/util/file"
+	netfactory "github.com/skycoin/skywire/pkg/net/factory"
 	"github.com/skycoin/skywire/pkg/net/skycoin-messenger/factory"
 )
 
 var (
-	address  string
-	seedPath string
+	address     string
+	seedPath    string
+	compression bool
+	checksum    bool
+	aclPath     string
+	tlsCert     string
+	tlsKey      string
+	wsOrigins   string
+
+	trafficPath    string
+	trafficPeriod  time.Duration
+	trafficAddress string
+
+	udpIdleTimeout time.Duration
+
+	mailboxMsgs    int
+	mailboxMsgSize int
+	mailboxTTL     time.Duration
+	mailboxBytes   int
+
+	logJSON bool
 )
 
 func parseFlags() {
-	flag.StringVar(&address, "address", ":8080", "address to listen on")
+	flag.StringVar(&address, "address", ":8080", "address to listen on, prefix with ws:// to accept websocket connections, served over tls if -tls-cert is set, which tls:// and wss:// require")
+	flag.BoolVar(&compression, "compression", false, "compress large msgs for clients that support it")
+	flag.BoolVar(&checksum, "checksum", false, "checksum msgs for clients that support it")
+	flag.StringVar(&tlsCert, "tls-cert", "", "path of the pem encoded tls certificate, serves tls if set, reloaded once changed, e.g. renewed by certbot")
+	flag.StringVar(&tlsKey, "tls-key", "", "path of the pem encoded tls key")
+	flag.StringVar(&wsOrigins, "ws-origins", "", "comma separated origins browsers may open websocket connections from, all if empty")
+	flag.StringVar(&aclPath, "access-control", "", "path of the json file listing allowed and denied keys")
+	flag.StringVar(&trafficPath, "traffic-export", "", "path of the json file the traffic of each key is exported to")
+	flag.DurationVar(&trafficPeriod, "traffic-export-period", time.Minute, "period of the traffic export")
+	flag.StringVar(&trafficAddress, "traffic-address", "", "serve the traffic of each key as json on /traffic of this address, of one key with ?key=")
+	flag.DurationVar(&udpIdleTimeout, "udp-idle-timeout", 90*time.Second, "close transport udp conns of nodes that sent no msg or ping for this long")
+	flag.IntVar(&mailboxMsgs, "mailbox-msgs", 0, "max count of msgs queued for each offline key, 0 disables queueing")
+	flag.IntVar(&mailboxMsgSize, "mailbox-msg-size", 4096, "max size of a msg queued for an offline key")
+	flag.DurationVar(&mailboxTTL, "mailbox-ttl", time.Hour, "time msgs stay queued for an offline key")
+	flag.IntVar(&mailboxBytes, "mailbox-max-bytes", factory.DEFAULT_MAILBOX_MAX_BYTES, "max total size of the msgs queued for all offline keys, 0 means no limit")
+	flag.BoolVar(&logJSON, "log-json", false, "log json objects instead of text")
 	flag.StringVar(&seedPath, "seed-path", filepath.Join(file.UserHome(), ".skyim", "server", "keys.json"), "dir path to save seeds info")
 	flag.Parse()
 }
@@ -32,6 +70,47 @@ func main() {
 	f := factory.NewMessengerFactory()
 	f.SetDefaultSeedConfigPath(seedPath)
 	f.SetLoggerLevel(factory.DebugLevel)
+	f.SetLoggerJSON(logJSON)
+	f.Compression = compression
+	f.Checksum = checksum
+	f.UDPIdleTimeout = udpIdleTimeout
+	if len(tlsCert) > 0 || len(tlsKey) > 0 {
+		config, err := netfactory.LoadTLSConfig(tlsCert, tlsKey)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		f.TLSConfig = config
+	}
+	if len(wsOrigins) > 0 {
+		f.WSOrigins = strings.Split(wsOrigins, ",")
+	}
+	if len(aclPath) > 0 {
+		ac, err := factory.ReadAccessControl(aclPath)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		f.SetAccessControl(ac)
+	}
+	if len(trafficPath) > 0 {
+		f.ExportTraffic(trafficPath, trafficPeriod)
+	}
+	if len(trafficAddress) > 0 {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/traffic", f.ServeTraffic)
+		go func() {
+			err := http.ListenAndServe(trafficAddress, mux)
+			if err != nil {
+				log.Errorf("traffic http server err %v", err)
+			}
+		}()
+	}
+	if mailboxMsgs > 0 {
+		mb := factory.NewMailbox(mailboxMsgSize, mailboxMsgs, mailboxTTL)
+		mb.MaxBytes = mailboxBytes
+		f.SetMailbox(mb)
+	}
 	err := f.Listen(address)
 	log.Debugf("listen on %s", address)
 	if err != nil {
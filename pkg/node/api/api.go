@@ -47,6 +47,22 @@ type appCxt struct {
 	cxt    context.Context
 	cancel context.CancelFunc
 	ok     chan struct{}
+
+	// executable and args to restart the app with
+	bin    string
+	args   []string
+	limits appLimits
+	// environment of the app, the one of the node if nil
+	env []string
+	// run before every (re)start if not nil, returns the executable to run
+	// instead of bin and a dir to remove once it exited
+	prepare func() (bin, dir string, err error)
+
+	running  bool
+	restarts int
+	crashes  []time.Time
+	err      error
+	sync.Mutex
 }
 
 func (cxt *appCxt) shutdown() {
@@ -102,9 +118,11 @@ func (na *NodeApi) StartSrv() {
 	if err != nil {
 		log.Errorf("after launch error: %s", err)
 	}
+	na.node.AddMetricsCollector(na.writeAppMetrics)
 	http.HandleFunc("/node/getSig", na.wrap(na.getSig))
 	http.HandleFunc("/node/getInfo", na.wrap(na.getInfo))
 	http.HandleFunc("/node/getMsg", na.wrap(na.getMsg))
+	http.HandleFunc("/node/getLinkEvents", na.wrap(na.getLinkEvents))
 	http.HandleFunc("/node/getApps", na.wrap(na.getApps))
 	http.HandleFunc("/node/reboot", na.wrap(na.runReboot))
 	http.HandleFunc("/node/run/sshs", na.wrap(na.runSshs))
@@ -123,6 +141,11 @@ func (na *NodeApi) StartSrv() {
 	http.HandleFunc("/node/run/getAutoStartConfig", na.wrap(na.getAutoStartConfig))
 	http.HandleFunc("/node/run/setAutoStartConfig", na.wrap(na.setAutoStartConfig))
 	http.HandleFunc("/node/run/closeApp", na.wrap(na.closeApp))
+	http.HandleFunc("/node/run/closeTransport", na.wrap(na.closeTransport))
+	http.HandleFunc("/node/run/restartApp", na.wrap(na.restartApp))
+	http.HandleFunc("/node/run/getAppStatus", na.wrap(na.getAppStatus))
+	http.HandleFunc("/node/getAppManifests", na.wrap(na.getAppManifests))
+	http.HandleFunc("/node/run/startApp", na.wrap(na.runApp))
 	http.HandleFunc("/node/run/term", na.handleXtermsocket)
 	na.srv.Handler = http.DefaultServeMux
 	go func() {
@@ -175,6 +198,19 @@ func (na *NodeApi) closeApp(w http.ResponseWriter, r *http.Request) (result []by
 	return
 }
 
+func (na *NodeApi) closeTransport(w http.ResponseWriter, r *http.Request) (result []byte, err error) {
+	id, err := strconv.ParseUint(r.FormValue("id"), 10, 64)
+	if err != nil {
+		return
+	}
+	if !na.node.CloseTransport(id) {
+		err = errors.New("Transport not found!")
+		return
+	}
+	result = []byte("true")
+	return
+}
+
 func (na *NodeApi) getInfo(w http.ResponseWriter, r *http.Request) (result []byte, err error) {
 	result, err = json.Marshal(na.node.GetNodeInfo())
 	if err != nil {
@@ -183,6 +219,23 @@ func (na *NodeApi) getInfo(w http.ResponseWriter, r *http.Request) (result []byt
 	return
 }
 
+// Poll the link events after the seq since, all kept ones if since is empty
+func (na *NodeApi) getLinkEvents(w http.ResponseWriter, r *http.Request) (result []byte, err error) {
+	var since uint64
+	if s := r.FormValue("since"); len(s) > 0 {
+		since, err = strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return
+		}
+	}
+	events := na.node.GetLinkEvents(since)
+	if events == nil {
+		events = []node.NodeLinkEvent{}
+	}
+	result, err = json.Marshal(events)
+	return
+}
+
 func (na *NodeApi) getMsg(w http.ResponseWriter, r *http.Request) (result []byte, err error) {
 	k, err := cipher.PubKeyFromHex(r.FormValue("key"))
 	if err != nil {
@@ -263,7 +316,6 @@ func (na *NodeApi) runSshc(w http.ResponseWriter, r *http.Request) (result []byt
 }
 
 func (na *NodeApi) startSshc(toNode, toApp, disvoerveryKey string) (err error) {
-	var gopath = os.Getenv("GOPATH")
 	if len(toNode) == 0 || len(toNode) < 66 {
 		err = errors.New("Node Key at least 66 characters.")
 		return
@@ -272,29 +324,9 @@ func (na *NodeApi) startSshc(toNode, toApp, disvoerveryKey string) (err error) {
 		err = errors.New("App Key at least 66 characters.")
 		return
 	}
-	key := "sshc"
-	app := na.apps[key]
-	if app != nil {
-		app.shutdown()
-	}
-	cxt, cancel := context.WithCancel(context.Background())
-	isOk := make(chan struct{})
-	app = &appCxt{
-		cxt:    cxt,
-		cancel: cancel,
-		ok:     isOk,
-	}
-	na.apps[key] = app
-	cmd := exec.CommandContext(app.cxt, filepath.Join(gopath, "bin", "sshc"), "-node-key",
-		toNode, "-app-key", toApp, "-discovery-key", disvoerveryKey, "-node-address", na.node.GetListenAddress())
-	err = cmd.Start()
-	if err != nil {
-		return
-	}
-	go func() {
-		cmd.Wait()
-		close(isOk)
-	}()
+	key := appSshc
+	err = na.startApp(key, "-node-key", toNode, "-app-key", toApp,
+		"-discovery-key", disvoerveryKey, "-node-address", na.node.GetListenAddress())
 	return
 }
 
@@ -313,7 +345,6 @@ func (na *NodeApi) runSocksc(w http.ResponseWriter, r *http.Request) (result []b
 }
 
 func (na *NodeApi) startSocksc(toNode, toApp, disvoerveryKey string) (err error) {
-	var gopath = os.Getenv("GOPATH")
 	if len(toNode) == 0 || len(toNode) < 66 {
 		err = errors.New("Node Key at least 66 characters.")
 		return
@@ -322,29 +353,9 @@ func (na *NodeApi) startSocksc(toNode, toApp, disvoerveryKey string) (err error)
 		err = errors.New("App Key at least 66 characters.")
 		return
 	}
-	key := "socksc"
-	app := na.apps[key]
-	if app != nil {
-		app.shutdown()
-	}
-	cxt, cancel := context.WithCancel(context.Background())
-	isOk := make(chan struct{})
-	app = &appCxt{
-		cxt:    cxt,
-		cancel: cancel,
-		ok:     isOk,
-	}
-	na.apps[key] = app
-	cmd := exec.CommandContext(app.cxt, filepath.Join(gopath, "bin", "socksc"), "-node-key",
-		toNode, "-app-key", toApp, "-discovery-key", disvoerveryKey, "-node-address", na.node.GetListenAddress())
-	err = cmd.Start()
-	if err != nil {
-		return
-	}
-	go func() {
-		cmd.Wait()
-		close(isOk)
-	}()
+	key := appSocksc
+	err = na.startApp(key, "-node-key", toNode, "-app-key", toApp,
+		"-discovery-key", disvoerveryKey, "-node-address", na.node.GetListenAddress())
 	return
 }
 
@@ -363,22 +374,9 @@ func (na *NodeApi) runSshs(w http.ResponseWriter, r *http.Request) (result []byt
 }
 
 func (na *NodeApi) startSshs(arr []string) (err error) {
-	var gopath = os.Getenv("GOPATH")
 	na.Lock()
 	defer na.Unlock()
-	key := "sshs"
-	app := na.apps[key]
-	if app != nil {
-		app.shutdown()
-	}
-	cxt, cancel := context.WithCancel(context.Background())
-	isOk := make(chan struct{})
-	app = &appCxt{
-		cxt:    cxt,
-		cancel: cancel,
-		ok:     isOk,
-	}
-	na.apps[key] = app
+	key := appSshs
 	args := make([]string, 0, len(arr)+2)
 	args = append(args, "-node-address")
 	args = append(args, na.node.GetListenAddress())
@@ -386,15 +384,7 @@ func (na *NodeApi) startSshs(arr []string) (err error) {
 		args = append(args, "-node-key")
 		args = append(args, v)
 	}
-	cmd := exec.CommandContext(app.cxt, filepath.Join(gopath, "bin", "sshs"), args...)
-	err = cmd.Start()
-	if err != nil {
-		return
-	}
-	go func() {
-		cmd.Wait()
-		close(isOk)
-	}()
+	err = na.startApp(key, args...)
 	return
 }
 
@@ -408,35 +398,24 @@ func (na *NodeApi) runSockss(w http.ResponseWriter, r *http.Request) (result []b
 }
 
 func (na *NodeApi) startSockss() (err error) {
-	var gopath = os.Getenv("GOPATH")
 	na.Lock()
 	defer na.Unlock()
-	key := "sockss"
-	app := na.apps[key]
-	if app != nil {
-		app.shutdown()
-	}
-	cxt, cancel := context.WithCancel(context.Background())
-	isOk := make(chan struct{})
-	app = &appCxt{
-		cxt:    cxt,
-		cancel: cancel,
-		ok:     isOk,
-	}
-	na.apps[key] = app
-	cmd := exec.CommandContext(app.cxt, filepath.Join(gopath, "bin", "sockss"), "-node-address", na.node.GetListenAddress())
-	err = cmd.Start()
-	if err != nil {
-		return
-	}
-	go func() {
-		cmd.Wait()
-		close(isOk)
-	}()
-
+	key := appSockss
+	err = na.startApp(key, "-node-address", na.node.GetListenAddress())
 	return
 }
 
+// Apps started from GOPATH/bin by the handlers above, a manifest must not
+// take their names
+const (
+	appSshs   = "sshs"
+	appSshc   = "sshc"
+	appSockss = "sockss"
+	appSocksc = "socksc"
+)
+
+var builtinApps = []string{appSshs, appSshc, appSockss, appSocksc}
+
 var scriptPath = "/src/github.com/skycoin/skywire/static/script/"
 
 func (na *NodeApi) checkUpdate(w http.ResponseWriter, r *http.Request) (result []byte, err error) {
@@ -485,8 +464,7 @@ func (na *NodeApi) setNodeConfig(w http.ResponseWriter, r *http.Request) (result
 	if err != nil {
 		return
 	}
-	cfs := &node.NodeConfigs{}
-	err = node.LoadConfig(cfs, na.confPath)
+	cfs, _, err := node.LoadNodeConfigs(na.confPath)
 	if err != nil {
 		return
 	}
@@ -523,11 +501,33 @@ func (na *NodeApi) restart() (err error) {
 	args = append(args, "-seed-path", na.config.SeedPath)
 	args = append(args, "-web-port", na.config.WebPort)
 	args = append(args, "-conf", na.confPath)
+	args = append(args, "-apps-path", na.config.AppsPath)
+	args = append(args, "-discovery-selector", na.config.DiscoverySelector)
+	for _, v := range na.config.DiscoveryPriority {
+		args = append(args, "-discovery-priority", v)
+	}
+	args = append(args, "-max-discoveries", strconv.Itoa(na.config.MaxDiscoveries))
+	args = append(args, fmt.Sprintf("-compression=%t", na.config.Compression))
+	args = append(args, fmt.Sprintf("-checksum=%t", na.config.Checksum))
+	args = append(args, "-app-timeout", na.config.AppTimeout.String())
+	args = append(args, "-app-max-memory", strconv.Itoa(na.config.AppMaxMemory))
+	args = append(args, "-app-max-files", strconv.Itoa(na.config.AppMaxFiles))
+	args = append(args, "-app-max-cpu", strconv.Itoa(na.config.AppMaxCPU))
+	args = append(args, fmt.Sprintf("-log-json=%t", na.config.LogJSON))
+	if len(na.config.LocalAddress) > 0 {
+		args = append(args, "-local-address", na.config.LocalAddress)
+	}
+	if len(na.config.MetricsAddress) > 0 {
+		args = append(args, "-metrics-address", na.config.MetricsAddress)
+	}
 	na.Close()
 	na.srv.Close()
 	na.node.Close()
 	time.Sleep(1000 * time.Millisecond)
 	cmd := exec.Command(filepath.Join(gopath, "bin", "skywire-node"), args...)
+	if len(na.config.MetricsAddress) > 0 && len(na.config.MetricsToken) > 0 {
+		cmd.Env = append(os.Environ(), node.MetricsTokenEnv+"="+na.config.MetricsToken)
+	}
 	err = cmd.Start()
 	if err != nil {
 		log.Errorf("cmd start err: %v", err)
@@ -768,6 +768,7 @@ func (na *NodeApi) afterLaunch() (err error) {
 			return
 		}
 	}
+	na.autoStartManifestApps()
 	if conf.Sockss {
 		log.Infof("start sockss...")
 		err = na.startSockss()
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Create the command running bin with the limits. The shell sets them before
+// it execs bin, so the app never runs without them.
+func (l appLimits) command(cxt context.Context, bin string, args ...string) (cmd *exec.Cmd, err error) {
+	if l.memory <= 0 && l.files <= 0 && l.cpu <= 0 {
+		cmd = exec.CommandContext(cxt, bin, args...)
+		return
+	}
+	var script []string
+	if l.memory > 0 {
+		// in KB
+		script = append(script, "ulimit -v "+strconv.Itoa(l.memory<<10))
+	}
+	if l.files > 0 {
+		script = append(script, "ulimit -n "+strconv.Itoa(l.files))
+	}
+	if l.cpu > 0 {
+		script = append(script, "ulimit -t "+strconv.Itoa(l.cpu))
+	}
+	script = append(script, `exec "$0" "$@"`)
+	cmd = exec.CommandContext(cxt, "/bin/sh", append([]string{"-c", strings.Join(script, " && "), bin}, args...)...)
+	return
+}
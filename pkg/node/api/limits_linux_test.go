@@ -0,0 +1,26 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAppLimitsCommand(t *testing.T) {
+	// the limits of a manifest only lower those of the node
+	limits := appLimits{files: 64, cpu: 5}.min(appLimits{memory: 1024, files: 32, cpu: 10})
+	if limits != (appLimits{memory: 1024, files: 32, cpu: 5}) {
+		t.Fatalf("expect the stricter limits, got %+v", limits)
+	}
+	cmd, err := limits.command(context.Background(), "/bin/sh", "-c", "ulimit -v; ulimit -n; ulimit -t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(string(out)); strings.Join(got, " ") != "1048576 32 5" {
+		t.Fatalf("expect limits 1048576 32 5, got %q", out)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package api
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+func (l appLimits) command(cxt context.Context, bin string, args ...string) (cmd *exec.Cmd, err error) {
+	cmd = exec.CommandContext(cxt, bin, args...)
+	if l.memory > 0 || l.files > 0 || l.cpu > 0 {
+		err = errors.New("app limits are only supported on linux")
+	}
+	return
+}
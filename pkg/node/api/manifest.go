@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/skycoin/skywire/pkg/node"
+)
+
+// Start the app of the manifest m, see node.AppManifest
+func (na *NodeApi) startManifestApp(m *node.AppManifest) (err error) {
+	na.Lock()
+	defer na.Unlock()
+	app := na.apps[m.Name]
+	if app != nil {
+		// free the ports of the running app before the check
+		app.shutdown()
+		delete(na.apps, m.Name)
+	}
+	args := make([]string, 0, len(m.Args)+2)
+	args = append(args, m.Args...)
+	args = append(args, "-node-address", na.node.GetListenAddress())
+	limits := appLimits{memory: m.MaxMemory, files: m.MaxFiles, cpu: m.MaxCPU}
+	err = na.startBinary(m.Name, m.BinaryPath(), m.Env(), m.Prepare, limits, args...)
+	return
+}
+
+func (na *NodeApi) autoStartManifestApps() {
+	manifests, err := node.LoadAppManifests(na.config.AppsPath, builtinApps...)
+	if err != nil {
+		log.Errorf("load app manifests err: %s", err)
+		return
+	}
+	for _, m := range manifests {
+		if !m.AutoStart {
+			continue
+		}
+		log.Infof("start %s...", m.Name)
+		err = na.startManifestApp(m)
+		if err != nil {
+			log.Errorf("start %s err: %s", m.Name, err)
+		}
+	}
+}
+
+func (na *NodeApi) getAppManifests(w http.ResponseWriter, r *http.Request) (result []byte, err error) {
+	manifests, err := node.LoadAppManifests(na.config.AppsPath, builtinApps...)
+	if err != nil {
+		return
+	}
+	result, err = json.Marshal(manifests)
+	return
+}
+
+func (na *NodeApi) runApp(w http.ResponseWriter, r *http.Request) (result []byte, err error) {
+	name := r.FormValue("name")
+	if len(name) == 0 {
+		err = errors.New("Name is Empty!")
+		return
+	}
+	manifests, err := node.LoadAppManifests(na.config.AppsPath, builtinApps...)
+	if err != nil {
+		return
+	}
+	for _, m := range manifests {
+		if m.Name == name {
+			err = na.startManifestApp(m)
+			if err != nil {
+				return
+			}
+			result = []byte("true")
+			return
+		}
+	}
+	err = errors.New("App is not installed!")
+	return
+}
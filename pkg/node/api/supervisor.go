@@ -0,0 +1,265 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/skycoin/skywire/pkg/node"
+)
+
+const (
+	// wait before restarting a crashed app, doubled for every crash within
+	// appRestartWindow up to appMaxRestartBackoff
+	appRestartBackoff    = time.Second
+	appMaxRestartBackoff = time.Minute
+	appRestartWindow     = 10 * time.Minute
+	// give up an app that crashed more often than this within appRestartWindow
+	appMaxRestarts = 5
+)
+
+// resource limits of an app process, 0 means no limit
+type appLimits struct {
+	// max address space in MB
+	memory int
+	// max open files
+	files int
+	// max cpu seconds
+	cpu int
+}
+
+// The stricter of the limits l and o
+func (l appLimits) min(o appLimits) appLimits {
+	min := func(a, b int) int {
+		if a <= 0 || (b > 0 && b < a) {
+			return b
+		}
+		return a
+	}
+	return appLimits{memory: min(l.memory, o.memory), files: min(l.files, o.files), cpu: min(l.cpu, o.cpu)}
+}
+
+type AppStatus struct {
+	Running  bool   `json:"running"`
+	Restarts int    `json:"restarts"`
+	Err      string `json:"err,omitempty"`
+}
+
+// Start the app key from GOPATH/bin with args, see startBinary
+func (na *NodeApi) startApp(key string, args ...string) (err error) {
+	var gopath = os.Getenv("GOPATH")
+	return na.startBinary(key, filepath.Join(gopath, "bin", key), nil, nil, appLimits{}, args...)
+}
+
+// Start the executable bin with args and env as app key and restart it
+// whenever it crashes until it is shutdown, see appCxt for prepare. The app
+// runs with the stricter of limits and those of the node config. The caller
+// must hold na.Lock
+func (na *NodeApi) startBinary(key, bin string, env []string, prepare func() (bin, dir string, err error), limits appLimits, args ...string) (err error) {
+	app := na.apps[key]
+	if app != nil {
+		app.shutdown()
+		delete(na.apps, key)
+	}
+	cxt, cancel := context.WithCancel(context.Background())
+	app = &appCxt{
+		cxt:     cxt,
+		cancel:  cancel,
+		ok:      make(chan struct{}),
+		bin:     bin,
+		args:    args,
+		env:     env,
+		prepare: prepare,
+		limits:  limits,
+	}
+	if na.config != nil {
+		app.limits = limits.min(appLimits{memory: na.config.AppMaxMemory, files: na.config.AppMaxFiles, cpu: na.config.AppMaxCPU})
+	}
+	cmd, dir, err := app.start(key)
+	if err != nil {
+		cancel()
+		return
+	}
+	app.running = true
+	na.apps[key] = app
+	go app.supervise(key, cmd, dir)
+	return
+}
+
+// Start the app, dir is to be removed once it exited
+func (app *appCxt) start(key string) (cmd *exec.Cmd, dir string, err error) {
+	bin := app.bin
+	if app.prepare != nil {
+		bin, dir, err = app.prepare()
+		if err != nil {
+			log.Errorf("app %s check err: %s", key, err)
+			return
+		}
+	}
+	cmd, e := app.limits.command(app.cxt, bin, app.args...)
+	if e != nil {
+		log.Errorf("app %s limits err: %s", key, e)
+	}
+	cmd.Env = app.env
+	err = cmd.Start()
+	if err != nil && len(dir) > 0 {
+		os.RemoveAll(dir)
+	}
+	return
+}
+
+func (app *appCxt) supervise(key string, cmd *exec.Cmd, dir string) {
+	defer close(app.ok)
+	err := app.wait(cmd, dir)
+	for app.cxt.Err() == nil {
+		if err == nil {
+			log.Infof("app %s exited", key)
+			return
+		}
+		backoff, ok := app.crashed(err)
+		if !ok {
+			log.Errorf("app %s crashed %d times in %s, giving up: %s", key, appMaxRestarts+1, appRestartWindow, err)
+			return
+		}
+		log.Errorf("app %s crashed: %s, restarting in %s", key, err, backoff)
+		select {
+		case <-app.cxt.Done():
+			return
+		case <-time.After(backoff):
+		}
+		cmd, dir, err = app.start(key)
+		if err != nil {
+			continue
+		}
+		app.Lock()
+		app.restarts++
+		app.Unlock()
+		err = app.wait(cmd, dir)
+	}
+}
+
+func (app *appCxt) wait(cmd *exec.Cmd, dir string) (err error) {
+	app.Lock()
+	app.running = true
+	app.Unlock()
+	err = cmd.Wait()
+	if len(dir) > 0 {
+		os.RemoveAll(dir)
+	}
+	app.Lock()
+	app.running = false
+	app.err = err
+	app.Unlock()
+	return
+}
+
+// Record a crash, return the time to wait before the restart or false if the
+// app crashed too often
+func (app *appCxt) crashed(err error) (backoff time.Duration, ok bool) {
+	now := time.Now()
+	app.Lock()
+	defer app.Unlock()
+	app.err = err
+	crashes := app.crashes[:0]
+	for _, t := range app.crashes {
+		if now.Sub(t) < appRestartWindow {
+			crashes = append(crashes, t)
+		}
+	}
+	app.crashes = append(crashes, now)
+	if len(app.crashes) > appMaxRestarts {
+		return
+	}
+	backoff = appRestartBackoff << uint(len(app.crashes)-1)
+	if backoff > appMaxRestartBackoff {
+		backoff = appMaxRestartBackoff
+	}
+	ok = true
+	return
+}
+
+func (app *appCxt) status() (s AppStatus) {
+	app.Lock()
+	s.Running = app.running
+	s.Restarts = app.restarts
+	if app.err != nil {
+		s.Err = app.err.Error()
+	}
+	app.Unlock()
+	return
+}
+
+func (na *NodeApi) restartApp(w http.ResponseWriter, r *http.Request) (result []byte, err error) {
+	key := r.FormValue("key")
+	if len(key) == 0 {
+		err = errors.New("Key is Empty!")
+		return
+	}
+	na.Lock()
+	defer na.Unlock()
+	app, ok := na.apps[key]
+	if !ok || app == nil {
+		err = errors.New("App is not started!")
+		return
+	}
+	err = na.startBinary(key, app.bin, app.env, app.prepare, app.limits, app.args...)
+	if err != nil {
+		return
+	}
+	result = []byte("true")
+	return
+}
+
+func (na *NodeApi) getAppStatus(w http.ResponseWriter, r *http.Request) (result []byte, err error) {
+	status := make(map[string]AppStatus)
+	na.RLock()
+	for k, v := range na.apps {
+		if v != nil {
+			status[k] = v.status()
+		}
+	}
+	na.RUnlock()
+	result, err = json.Marshal(status)
+	return
+}
+
+func (na *NodeApi) writeAppMetrics(w io.Writer) {
+	status := make(map[string]AppStatus)
+	var keys []string
+	na.RLock()
+	for k, v := range na.apps {
+		if v != nil {
+			status[k] = v.status()
+			keys = append(keys, k)
+		}
+	}
+	na.RUnlock()
+	sort.Strings(keys)
+	for i, k := range keys {
+		var running float64
+		if status[k].Running {
+			running = 1
+		}
+		if i == 0 {
+			node.WriteMetric(w, "skywire_node_app_running", "gauge", "Whether the app started by the node is running.", running, "app", k)
+		} else {
+			node.WriteMetricSample(w, "skywire_node_app_running", running, "app", k)
+		}
+	}
+	for i, k := range keys {
+		restarts := float64(status[k].Restarts)
+		if i == 0 {
+			node.WriteMetric(w, "skywire_node_app_restarts_total", "counter", "Restarts of the app after crashes.", restarts, "app", k)
+		} else {
+			node.WriteMetricSample(w, "skywire_node_app_restarts_total", restarts, "app", k)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const AppManifestExt = ".json"
+
+// Permissions an app manifest may grant
+const (
+	// listen on the ports of the manifest
+	PermissionListen = "listen"
+	// run with the environment of the node instead of only PATH and HOME
+	PermissionEnv = "env"
+)
+
+var appPermissions = map[string]struct{}{
+	PermissionListen: {},
+	PermissionEnv:    {},
+}
+
+// Manifest of an app installed in the apps directory of the node
+type AppManifest struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// path of the executable, relative to the apps directory if not absolute
+	Binary string `json:"binary"`
+	// hex sha256 of the executable, not checked if empty
+	Hash string `json:"hash"`
+	// args to start the app with, the node appends -node-address
+	Args []string `json:"args"`
+	// local tcp ports the app listens on, checked to be free before start,
+	// require the listen permission
+	Ports []int `json:"ports"`
+	// what the app may do besides connecting to the node, see Permission*
+	Permissions []string `json:"permissions"`
+	// limits of the app process, 0 means the limit of the node config, which
+	// they only lower. Max address space in MB, open files and cpu seconds
+	MaxMemory int `json:"max_memory"`
+	MaxFiles  int `json:"max_files"`
+	MaxCPU    int `json:"max_cpu"`
+	// start the app when the node starts
+	AutoStart bool `json:"auto_start"`
+
+	dir string
+}
+
+// Load the manifests of the apps directory, invalid manifests and those
+// taking a reserved name, e.g. of an app built into the node, are logged and
+// skipped. A missing directory has no apps.
+func LoadAppManifests(dir string, reserved ...string) (manifests []*AppManifest, err error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	names := make(map[string]struct{})
+	for _, name := range reserved {
+		names[name] = struct{}{}
+	}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != AppManifestExt {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		m, e := readAppManifest(path)
+		if e != nil {
+			log.Errorf("app manifest %s err: %s", path, e)
+			continue
+		}
+		if _, ok := names[m.Name]; ok {
+			log.Errorf("app manifest %s err: duplicate or reserved app %s", path, m.Name)
+			continue
+		}
+		names[m.Name] = struct{}{}
+		manifests = append(manifests, m)
+	}
+	return
+}
+
+func readAppManifest(path string) (m *AppManifest, err error) {
+	fb, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	m = &AppManifest{}
+	err = json.Unmarshal(fb, m)
+	if err != nil {
+		return
+	}
+	if len(m.Name) == 0 {
+		err = errors.New("name is empty")
+		return
+	}
+	if len(m.Binary) == 0 {
+		err = errors.New("binary is empty")
+		return
+	}
+	for _, p := range m.Permissions {
+		if _, ok := appPermissions[p]; !ok {
+			err = fmt.Errorf("unknown permission %s", p)
+			return
+		}
+	}
+	if m.MaxMemory < 0 || m.MaxFiles < 0 || m.MaxCPU < 0 {
+		err = errors.New("negative limit")
+		return
+	}
+	if len(m.Ports) > 0 && !m.HasPermission(PermissionListen) {
+		err = fmt.Errorf("ports without the %s permission", PermissionListen)
+		return
+	}
+	m.dir = filepath.Dir(path)
+	return
+}
+
+// Path of the executable of the app
+func (m *AppManifest) BinaryPath() string {
+	if filepath.IsAbs(m.Binary) {
+		return m.Binary
+	}
+	return filepath.Join(m.dir, m.Binary)
+}
+
+func (m *AppManifest) HasPermission(permission string) bool {
+	for _, p := range m.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Environment to run the app with, nil to keep the one of the node
+func (m *AppManifest) Env() []string {
+	if m.HasPermission(PermissionEnv) {
+		return nil
+	}
+	return []string{"PATH=" + os.Getenv("PATH"), "HOME=" + os.Getenv("HOME")}
+}
+
+// Copy the executable to a new private dir, checking the copy against the
+// hash, so the binary run is the one checked even if the original is
+// replaced meanwhile. Check the ports to be free. The caller removes dir
+// once the app exited.
+func (m *AppManifest) Prepare() (bin, dir string, err error) {
+	src, err := os.Open(m.BinaryPath())
+	if err != nil {
+		return
+	}
+	defer src.Close()
+	dir, err = ioutil.TempDir("", "skywire-app-")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+			dir = ""
+		}
+	}()
+	bin = filepath.Join(dir, filepath.Base(m.BinaryPath()))
+	dst, err := os.OpenFile(bin, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0700)
+	if err != nil {
+		return
+	}
+	h := sha256.New()
+	_, err = io.Copy(dst, io.TeeReader(src, h))
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return
+	}
+	if len(m.Hash) > 0 && hex.EncodeToString(h.Sum(nil)) != m.Hash {
+		err = fmt.Errorf("hash mismatch of %s", m.BinaryPath())
+		return
+	}
+	for _, p := range m.Ports {
+		var ln net.Listener
+		ln, err = net.Listen("tcp", ":"+strconv.Itoa(p))
+		if err != nil {
+			err = fmt.Errorf("port %d is not free: %s", p, err)
+			return
+		}
+		ln.Close()
+	}
+	return
+}
@@ -0,0 +1,106 @@
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAppManifestsBuiltinName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, manifest := range map[string]string{
+		"sshs.json":  `{"name": "sshs", "binary": "sshs"}`,
+		"myapp.json": `{"name": "myapp", "binary": "myapp"}`,
+	} {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(manifest), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifests, err := LoadAppManifests(dir, "sshs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifests) != 1 || manifests[0].Name != "myapp" {
+		t.Fatalf("expect only myapp, got %v", manifests)
+	}
+}
+
+func TestLoadAppManifestsPermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, manifest := range map[string]string{
+		"unknown.json": `{"name": "unknown", "binary": "a", "permissions": ["root"]}`,
+		"ports.json":   `{"name": "ports", "binary": "a", "ports": [28444]}`,
+		"limit.json":   `{"name": "limit", "binary": "a", "max_cpu": -1}`,
+		"listen.json":  `{"name": "listen", "binary": "a", "ports": [28444], "permissions": ["listen"]}`,
+	} {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(manifest), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifests, err := LoadAppManifests(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifests) != 1 || manifests[0].Name != "listen" {
+		t.Fatalf("expect only listen, got %v", manifests)
+	}
+	if env := manifests[0].Env(); len(env) != 2 {
+		t.Fatalf("expect only PATH and HOME without the env permission, got %v", env)
+	}
+}
+
+func TestAppManifestPrepare(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := []byte("#!/bin/sh\n")
+	hash := sha256.Sum256(content)
+	err = ioutil.WriteFile(filepath.Join(dir, "app"), content, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &AppManifest{Name: "app", Binary: "app", Hash: hex.EncodeToString(hash[:]), dir: dir}
+	bin, runDir, err := m.Prepare()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(runDir)
+	// the checked copy is run, replacing the original does not change it
+	err = ioutil.WriteFile(filepath.Join(dir, "app"), []byte("#!/bin/sh\nevil\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(content) {
+		t.Fatalf("expect the checked binary, got %q", got)
+	}
+
+	_, runDir, err = m.Prepare()
+	if err == nil {
+		t.Fatal("expect hash mismatch")
+	}
+	if len(runDir) > 0 {
+		t.Fatalf("expect no dir left on error, got %s", runDir)
+	}
+}
@@ -0,0 +1,138 @@
+package node
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skywire/pkg/net/skycoin-messenger/factory"
+)
+
+// env var holding the metrics token, keeps it out of the args shown by ps
+const MetricsTokenEnv = "SKYWIRE_METRICS_TOKEN"
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Write one metric in the prometheus text format, labels are name value pairs
+func WriteMetric(w io.Writer, name, typ, help string, value float64, labels ...string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	WriteMetricSample(w, name, value, labels...)
+}
+
+// Write one more sample of the metric written last, e.g. with other labels
+func WriteMetricSample(w io.Writer, name string, value float64, labels ...string) {
+	var l []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		l = append(l, fmt.Sprintf(`%s="%s"`, labels[i], labelValueEscaper.Replace(labels[i+1])))
+	}
+	if len(l) > 0 {
+		name += "{" + strings.Join(l, ",") + "}"
+	}
+	fmt.Fprintf(w, "%s %s\n", name, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// Add fn to the metrics served by ServeMetrics, e.g. the metrics of the apps
+// started by the node api
+func (n *Node) AddMetricsCollector(fn func(w io.Writer)) {
+	n.metricsMutex.Lock()
+	n.metricsCollectors = append(n.metricsCollectors, fn)
+	n.metricsMutex.Unlock()
+}
+
+// Serve the metrics of the node on /metrics of addr in the prometheus text
+// format, a bearer token or token arg is required if token is not empty
+func (n *Node) ServeMetrics(addr, token string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if len(token) > 0 {
+			t := r.FormValue("token")
+			if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+				t = strings.TrimPrefix(h, "Bearer ")
+			}
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		var buf bytes.Buffer
+		n.writeMetrics(&buf)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write(buf.Bytes())
+	})
+	srv := &http.Server{Addr: addr, Handler: mux}
+	n.metricsMutex.Lock()
+	n.metricsSrv = srv
+	n.metricsMutex.Unlock()
+	go func() {
+		log.Debugf("metrics server listening on %s", addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Errorf("metrics server: ListenAndServe() error: %s", err)
+		}
+	}()
+}
+
+func (n *Node) writeMetrics(w io.Writer) {
+	WriteMetric(w, "skywire_node_info", "gauge", "Version of the node.", 1, "version", Version, "tag", Tag)
+
+	var appConns int
+	n.apps.ForEachAcceptedConnection(func(key cipher.PubKey, conn *factory.Connection) {
+		appConns++
+	})
+	WriteMetric(w, "skywire_node_app_conns", "gauge", "Apps connected to the node.", float64(appConns))
+
+	ni := n.GetNodeInfo()
+	var addrs []string
+	for k := range ni.Discoveries {
+		addrs = append(addrs, k)
+	}
+	sort.Strings(addrs)
+	for i, k := range addrs {
+		var v float64
+		if ni.Discoveries[k] {
+			v = 1
+		}
+		if i == 0 {
+			WriteMetric(w, "skywire_node_discovery_connected", "gauge", "Whether the node is connected to the discovery.", v, "address", k)
+		} else {
+			WriteMetricSample(w, "skywire_node_discovery_connected", v, "address", k)
+		}
+	}
+
+	var conns, upload, download, uploadBW, downloadBW float64
+	for _, t := range ni.Transports {
+		conns += float64(t.Conns)
+		upload += float64(t.UploadTotal)
+		download += float64(t.DownloadTotal)
+		uploadBW += float64(t.UploadBW)
+		downloadBW += float64(t.DownloadBW)
+	}
+	WriteMetric(w, "skywire_node_transports", "gauge", "Open transports between apps.", float64(len(ni.Transports)))
+	WriteMetric(w, "skywire_node_transport_conns", "gauge", "App conns over the open transports.", conns)
+	WriteMetric(w, "skywire_node_transport_upload_bytes", "gauge", "Bytes uploaded over the open transports.", upload)
+	WriteMetric(w, "skywire_node_transport_download_bytes", "gauge", "Bytes downloaded over the open transports.", download)
+	WriteMetric(w, "skywire_node_transport_upload_bandwidth", "gauge", "Upload bandwidth of the open transports in bytes per second.", uploadBW)
+	WriteMetric(w, "skywire_node_transport_download_bandwidth", "gauge", "Download bandwidth of the open transports in bytes per second.", downloadBW)
+
+	n.metricsMutex.RLock()
+	collectors := n.metricsCollectors
+	n.metricsMutex.RUnlock()
+	for _, fn := range collectors {
+		fn(w)
+	}
+}
+
+func (n *Node) closeMetrics() {
+	n.metricsMutex.RLock()
+	srv := n.metricsSrv
+	n.metricsMutex.RUnlock()
+	if srv != nil {
+		srv.Close()
+	}
+}
@@ -0,0 +1,65 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// version of the node config file written by this node
+const ConfigVersion = 2
+
+// migrations of the node config file, configMigrations[i] upgrades version i to i+1
+var configMigrations = []func(cfs *NodeConfigs){
+	// 0: files written before the version was stored, same layout as 1
+	func(cfs *NodeConfigs) {},
+	// 1: nodes saved without discoveries can't start, give them the defaults
+	func(cfs *NodeConfigs) {
+		for _, c := range cfs.Configs {
+			if c != nil && len(c.DiscoveryAddresses) == 0 {
+				c.DiscoveryAddresses = NewNodeConf().DiscoveryAddresses
+			}
+		}
+	},
+}
+
+// Load the node configs of path and migrate them to ConfigVersion, migrated is
+// true if they changed and should be written back. A missing file has no configs.
+func LoadNodeConfigs(path string) (cfs *NodeConfigs, migrated bool, err error) {
+	cfs = &NodeConfigs{
+		Configs: make(map[string]*Config),
+		Version: ConfigVersion,
+	}
+	fb, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	cfs.Version = 0
+	err = json.Unmarshal(fb, cfs)
+	if err != nil {
+		return
+	}
+	if cfs.Configs == nil {
+		cfs.Configs = make(map[string]*Config)
+	}
+	migrated, err = MigrateConfig(cfs)
+	return
+}
+
+// Upgrade cfs to ConfigVersion, fails on configs of a newer node
+func MigrateConfig(cfs *NodeConfigs) (migrated bool, err error) {
+	if cfs.Version > ConfigVersion || cfs.Version < 0 {
+		err = fmt.Errorf("config version %d is not supported, max version %d", cfs.Version, ConfigVersion)
+		return
+	}
+	for cfs.Version < ConfigVersion {
+		configMigrations[cfs.Version](cfs)
+		cfs.Version++
+		migrated = true
+	}
+	return
+}
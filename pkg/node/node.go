@@ -3,12 +3,16 @@ package node
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -42,9 +46,24 @@ type Node struct {
 
 	discoveries   Addresses
 	onDiscoveries sync.Map
+	selector      ServerSelector
+	closed        int32
+
+	// discoveries the node connects or reconnects to
+	discoveryConns map[string]*discoveryConn
+	discoveryMutex sync.Mutex
 
 	srs      []*SearchResult
 	srsMutex sync.Mutex
+
+	// recent link events of the conns to discoveries and manager
+	linkEvents      []NodeLinkEvent
+	linkEventSeq    uint64
+	linkEventsMutex sync.Mutex
+
+	metricsCollectors []func(w io.Writer)
+	metricsSrv        *http.Server
+	metricsMutex      sync.RWMutex
 }
 
 type Config struct {
@@ -56,7 +75,29 @@ type Config struct {
 	Seed               bool      `json:"seed"`
 	SeedPath           string    `json:"seed_path"`
 	AutoStartPath      string    `json:"auto_start_path"`
+	AppsPath           string    `json:"apps_path"`
 	WebPort            string    `json:"web_port"`
+	DiscoverySelector  string    `json:"discovery_selector"`
+	DiscoveryPriority  Addresses `json:"discovery_priority"`
+	MaxDiscoveries     int       `json:"max_discoveries"`
+	Compression        bool      `json:"compression"`
+	Checksum           bool      `json:"checksum"`
+	LocalAddress       string    `json:"local_address"`
+
+	AppTimeout time.Duration `json:"app_timeout"`
+
+	// limits of the apps started by the node api, 0 means no limit
+	AppMaxMemory int `json:"app_max_memory"`
+	AppMaxFiles  int `json:"app_max_files"`
+	// in cpu seconds, the app is killed by SIGXCPU once it used them
+	AppMaxCPU int `json:"app_max_cpu"`
+
+	// serve prometheus metrics on /metrics of this address if not empty
+	MetricsAddress string `json:"metrics_address"`
+	MetricsToken   string `json:"metrics_token"`
+
+	// log json objects instead of text
+	LogJSON bool `json:"log_json"`
 }
 
 type NodeConfigs struct {
@@ -77,20 +118,129 @@ func New(seedPath, launchConfigPath, webPort string) *Node {
 	m := factory.NewMessengerFactory()
 	m.SetDefaultSeedConfigPath(seedPath)
 	m.SetAppVersion(Version)
-	return &Node{
+	n := &Node{
 		apps:             apps,
 		manager:          m,
 		seedConfigPath:   seedPath,
 		launchConfigPath: launchConfigPath,
 		webPort:          webPort,
+		selector:         AllSelector{},
+		discoveryConns:   make(map[string]*discoveryConn),
+	}
+	n.SubscribeLinkEvents(n.recordLinkEvent)
+	return n
+}
+
+type discoveryConn struct {
+	host   string
+	config *factory.ConnConfig
+	// disconnected or failed to connect, reconnecting
+	down bool
+}
+
+// Set the strategy choosing which discovery servers to connect on Start
+func (n *Node) SetServerSelector(selector ServerSelector) {
+	n.selector = selector
+}
+
+// Negotiate compression of large msgs with discoveries, manager and apps
+func (n *Node) SetCompression(enable bool) {
+	n.apps.Compression = enable
+	n.manager.Compression = enable
+}
+
+// Log json objects instead of text, e.g. for log collectors
+func (n *Node) SetLogJSON(enable bool) {
+	n.apps.SetLoggerJSON(enable)
+}
+
+// Negotiate checksums of msgs with discoveries, manager and apps
+func (n *Node) SetChecksum(enable bool) {
+	n.apps.Checksum = enable
+	n.manager.Checksum = enable
+}
+
+// Dial discoveries and managers with dial instead of net.Dial
+func (n *Node) SetDialer(dial func(network, address string) (net.Conn, error)) {
+	n.apps.Dial = dial
+	n.manager.Dial = dial
+}
+
+// Call callback on link events of the connections to discoveries and manager,
+// until the returned func is called
+func (n *Node) SubscribeLinkEvents(callback func(factory.LinkEvent)) (unsubscribe func()) {
+	appsUnsub := n.apps.SubscribeLinkEvents(callback)
+	managerUnsub := n.manager.SubscribeLinkEvents(callback)
+	return func() {
+		appsUnsub()
+		managerUnsub()
+	}
+}
+
+// the count of link events kept for GetLinkEvents
+const maxLinkEvents = 100
+
+type NodeLinkEvent struct {
+	Seq     uint64    `json:"seq"`
+	Type    string    `json:"type"`
+	Address string    `json:"address"`
+	Key     string    `json:"key"`
+	Time    time.Time `json:"time"`
+}
+
+func (n *Node) recordLinkEvent(ev factory.LinkEvent) {
+	n.linkEventsMutex.Lock()
+	defer n.linkEventsMutex.Unlock()
+	n.linkEventSeq++
+	n.linkEvents = append(n.linkEvents, NodeLinkEvent{
+		Seq:     n.linkEventSeq,
+		Type:    ev.Type.String(),
+		Address: ev.Address,
+		Key:     ev.Key.Hex(),
+		Time:    ev.Time,
+	})
+	if len(n.linkEvents) > maxLinkEvents {
+		n.linkEvents = n.linkEvents[len(n.linkEvents)-maxLinkEvents:]
 	}
 }
 
+// Return the recent link events with a seq after since, oldest first
+func (n *Node) GetLinkEvents(since uint64) (events []NodeLinkEvent) {
+	n.linkEventsMutex.Lock()
+	defer n.linkEventsMutex.Unlock()
+	for _, ev := range n.linkEvents {
+		if ev.Seq > since {
+			events = append(events, ev)
+		}
+	}
+	return
+}
+
+// Create a dialer that binds the outgoing tcp connections to the local ip
+func LocalAddrDialer(ip string) (dial func(network, address string) (net.Conn, error), err error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		err = fmt.Errorf("invalid local address %s", ip)
+		return
+	}
+	d := &net.Dialer{LocalAddr: &net.TCPAddr{IP: addr}}
+	dial = d.Dial
+	return
+}
+
+// Close app conns that send nothing, not even a ping, for d. Call before Start.
+// The conns to discoveries keep the default timeout matching their ping period.
+func (n *Node) SetAppTimeout(d time.Duration) {
+	n.apps.AcceptedReadTimeout = d
+}
+
 func (n *Node) GetManager() *factory.MessengerFactory {
 	return n.manager
 }
 
 func (n *Node) Close() {
+	atomic.StoreInt32(&n.closed, 1)
+	n.closeMetrics()
 	n.apps.Close()
 	n.manager.Close()
 }
@@ -111,8 +261,19 @@ func (n *Node) Start(discoveries Addresses, address string) (err error) {
 		}()
 	}
 
-	for _, addr := range discoveries {
-		err = n.connectDiscovery(addr)
+	selected := n.selector.Select(discoveries)
+	if len(selected) < 1 && len(discoveries) > 0 {
+		log.Errorf("no discovery selected of %v", discoveries)
+		time.AfterFunc(discoveryReselectWait, n.reselectDiscoveries)
+	}
+	for _, addr := range selected {
+		n.discoveryMutex.Lock()
+		var d *discoveryConn
+		d, err = n.addDiscovery(addr)
+		n.discoveryMutex.Unlock()
+		if err == nil {
+			err = n.connectDiscovery(addr, d)
+		}
 		if err != nil {
 			log.Errorf("failed to connect addr(%s) err %v", addr, err)
 			return err
@@ -121,7 +282,61 @@ func (n *Node) Start(discoveries Addresses, address string) (err error) {
 	return
 }
 
-func (n *Node) connectDiscovery(addr string) (err error) {
+const discoveryReselectWait = 10 * time.Second
+
+// Select the discoveries again after one disconnected or none was selected,
+// so servers skipped before, e.g. unreachable on the latency probe, are
+// probed again. A newly selected server takes the place of a disconnected
+// one, so the node keeps at most as many servers as selected.
+func (n *Node) reselectDiscoveries() {
+	if atomic.LoadInt32(&n.closed) == 1 {
+		return
+	}
+	selected := n.selector.Select(n.discoveries)
+	if len(selected) < 1 && len(n.discoveries) > 0 {
+		time.AfterFunc(discoveryReselectWait, n.reselectDiscoveries)
+		return
+	}
+	added := make(map[string]*discoveryConn)
+	n.discoveryMutex.Lock()
+	for _, addr := range selected {
+		if _, ok := n.discoveryConns[addr]; ok {
+			continue
+		}
+		if len(n.discoveryConns) >= len(selected) && !n.dropDownDiscovery() {
+			break
+		}
+		d, err := n.addDiscovery(addr)
+		if err != nil {
+			log.Errorf("failed to connect addr(%s) err %v", addr, err)
+			continue
+		}
+		added[addr] = d
+	}
+	n.discoveryMutex.Unlock()
+	for addr, d := range added {
+		err := n.connectDiscovery(addr, d)
+		if err != nil {
+			log.Errorf("failed to connect addr(%s) err %v", addr, err)
+		}
+	}
+}
+
+// Stop reconnecting to a disconnected discovery, return false if every
+// discovery is connected. The caller must hold discoveryMutex.
+func (n *Node) dropDownDiscovery() bool {
+	for addr, d := range n.discoveryConns {
+		if d.down {
+			delete(n.discoveryConns, addr)
+			n.onDiscoveries.Delete(addr)
+			return true
+		}
+	}
+	return false
+}
+
+// Add the discovery addr to connect to, the caller must hold discoveryMutex
+func (n *Node) addDiscovery(addr string) (d *discoveryConn, err error) {
 	n.onDiscoveries.Store(addr, false)
 	split := strings.Split(addr, "-")
 	if len(split) != 2 {
@@ -133,11 +348,34 @@ func (n *Node) connectDiscovery(addr string) (err error) {
 		err = fmt.Errorf("discovery address %s is not valid", addr)
 		return
 	}
-	err = n.apps.ConnectWithConfig(split[0], &factory.ConnConfig{
-		TargetKey:     tk,
-		Reconnect:     true,
-		ReconnectWait: 10 * time.Second,
+	d = &discoveryConn{host: split[0]}
+	// the callbacks only track d while it is the discovery of addr, it
+	// stops reconnecting once dropped
+	current := func() bool {
+		n.discoveryMutex.Lock()
+		defer n.discoveryMutex.Unlock()
+		return n.discoveryConns[addr] == d
+	}
+	setDown := func(down bool) bool {
+		n.discoveryMutex.Lock()
+		defer n.discoveryMutex.Unlock()
+		if n.discoveryConns[addr] != d {
+			return false
+		}
+		d.down = down
+		n.onDiscoveries.Store(addr, !down)
+		return true
+	}
+	d.config = &factory.ConnConfig{
+		TargetKey:        tk,
+		Reconnect:        true,
+		ReconnectWait:    10 * time.Second,
+		KeepReconnecting: current,
 		OnConnected: func(connection *factory.Connection) {
+			if !setDown(false) {
+				connection.Close()
+				return
+			}
 			go func() {
 				for {
 					select {
@@ -150,13 +388,27 @@ func (n *Node) connectDiscovery(addr string) (err error) {
 				}
 			}()
 			n.apps.ResyncToDiscovery(connection)
-			n.onDiscoveries.Store(addr, true)
 		},
 		OnDisconnected: func(connection *factory.Connection) {
-			n.onDiscoveries.Store(addr, false)
+			if setDown(true) {
+				go n.reselectDiscoveries()
+			}
 		},
 		FindServiceNodesByAttributesCallback: n.searchResultCallback,
-	})
+	}
+	n.discoveryConns[addr] = d
+	return
+}
+
+func (n *Node) connectDiscovery(addr string, d *discoveryConn) (err error) {
+	err = n.apps.ConnectWithConfig(d.host, d.config)
+	if err != nil {
+		n.discoveryMutex.Lock()
+		if n.discoveryConns[addr] == d {
+			d.down = true
+		}
+		n.discoveryMutex.Unlock()
+	}
 	return
 }
 
@@ -198,6 +450,7 @@ func (n *Node) GetListenAddress() string {
 }
 
 type NodeTransport struct {
+	ID       uint64 `json:"id"`
 	FromNode string `json:"from_node"`
 	ToNode   string `json:"to_node"`
 	FromApp  string `json:"from_app"`
@@ -207,6 +460,9 @@ type NodeTransport struct {
 	DownloadBW    uint `json:"download_bandwidth"`
 	UploadTotal   uint `json:"upload_total"`
 	DownloadTotal uint `json:"download_total"`
+
+	Conns    int `json:"conns"`
+	MaxConns int `json:"max_conns"`
 }
 
 type NodeInfo struct {
@@ -230,7 +486,9 @@ func (n *Node) GetNodeInfo() (ni NodeInfo) {
 	var afs []FeedBackItem
 	n.apps.ForEachAcceptedConnection(func(key cipher.PubKey, conn *factory.Connection) {
 		conn.ForEachTransport(func(v *factory.Transport) {
+			conns, maxConns := v.GetConnIDUsage()
 			ts = append(ts, NodeTransport{
+				ID:            v.GetID(),
 				FromNode:      v.FromNode.Hex(),
 				ToNode:        v.ToNode.Hex(),
 				FromApp:       v.FromApp.Hex(),
@@ -239,6 +497,8 @@ func (n *Node) GetNodeInfo() (ni NodeInfo) {
 				DownloadBW:    v.GetDownloadBandwidth(),
 				UploadTotal:   v.GetUploadTotal(),
 				DownloadTotal: v.GetDownloadTotal(),
+				Conns:         conns,
+				MaxConns:      maxConns,
 			})
 		})
 		feedback := conn.GetAppFeedback()
@@ -276,6 +536,24 @@ func (n *Node) GetNodeInfo() (ni NodeInfo) {
 	return
 }
 
+// Close the transport with the id listed in GetNodeInfo, false if not found
+func (n *Node) CloseTransport(id uint64) (closed bool) {
+	var tr *factory.Transport
+	n.apps.ForEachAcceptedConnection(func(key cipher.PubKey, conn *factory.Connection) {
+		conn.ForEachTransport(func(v *factory.Transport) {
+			if v.GetID() == id {
+				tr = v
+			}
+		})
+	})
+	// Close removes the transport from its app conn, not while iterating
+	if tr != nil {
+		tr.Close()
+		closed = true
+	}
+	return
+}
+
 func (n *Node) GetMessages(key cipher.PubKey) []factory.PriorityMsg {
 	c, ok := n.apps.GetConnection(key)
 	if ok {
@@ -478,7 +756,7 @@ func LoadConfig(conf interface{}, filename string) (err error) {
 		if os.IsNotExist(err) {
 			conf := &NodeConfigs{
 				Configs: make(map[string]*Config),
-				Version: 1,
+				Version: ConfigVersion,
 			}
 			err = WriteConfig(conf, filename)
 			if err != nil {
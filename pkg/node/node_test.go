@@ -0,0 +1,342 @@
+package node
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skywire/pkg/app"
+	"github.com/skycoin/skywire/pkg/net/skycoin-messenger/factory"
+)
+
+// A free tcp and udp address on localhost
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// Start a discovery keeping its seed in dir, return its node address
+func listenDiscovery(t *testing.T, dir, name string) (discovery *factory.MessengerFactory, addr string) {
+	discovery = factory.NewMessengerFactory()
+	err := discovery.SetDefaultSeedConfigPath(filepath.Join(dir, name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := freeAddr(t)
+	err = discovery.Listen(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr = host + "-" + discovery.GetDefaultSeedConfig().PublicKey
+	return
+}
+
+func TestSetDialerDiscovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	discovery, discoveryAddr := listenDiscovery(t, dir, "discovery")
+	defer discovery.Close()
+
+	dial, err := LocalAddrDialer("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialed := make(chan net.Addr, 1)
+	n := New(filepath.Join(dir, "node.json"), filepath.Join(dir, "autoStart.json"), "")
+	n.SetDialer(func(network, address string) (c net.Conn, err error) {
+		c, err = dial(network, address)
+		if err == nil {
+			dialed <- c.LocalAddr()
+		}
+		return
+	})
+	defer n.Close()
+	// the discoveries are dialed by the factory listening for apps
+	err = n.Start(Addresses{discoveryAddr}, freeAddr(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var local net.Addr
+	select {
+	case local = <-dialed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("discovery not dialed with the dialer")
+	}
+	if !local.(*net.TCPAddr).IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatalf("expect local address 127.0.0.1, got %s", local)
+	}
+	// the discovery registers the conn after the node got its reply
+	for i := 0; ; i++ {
+		var remotes []string
+		discovery.ForEachAcceptedConnection(func(key cipher.PubKey, conn *factory.Connection) {
+			remotes = append(remotes, conn.GetRemoteAddr().String())
+		})
+		if len(remotes) > 0 {
+			if remotes[0] != local.String() {
+				t.Fatalf("expect discovery conn from %s, got %v", local, remotes)
+			}
+			break
+		}
+		if i == 50 {
+			t.Fatal("discovery has no conn")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Return the discoveries the node is connected to
+func connectedDiscoveries(n *Node) (addrs []string) {
+	n.onDiscoveries.Range(func(key, value interface{}) bool {
+		if value.(bool) {
+			addrs = append(addrs, key.(string))
+		}
+		return true
+	})
+	return
+}
+
+func TestReselectDiscoveries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	discoveries := make(map[string]*factory.MessengerFactory)
+	var addrs Addresses
+	for _, name := range []string{"d1", "d2"} {
+		discovery, addr := listenDiscovery(t, dir, name)
+		defer discovery.Close()
+		discoveries[addr] = discovery
+		addrs = append(addrs, addr)
+	}
+
+	n := New(filepath.Join(dir, "node.json"), filepath.Join(dir, "autoStart.json"), "")
+	// the latency probe skips the closed discovery
+	n.SetServerSelector(&LatencySelector{Max: 1, Timeout: time.Second})
+	defer n.Close()
+	err = n.Start(addrs, freeAddr(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	connected := connectedDiscoveries(n)
+	if len(connected) != 1 {
+		t.Fatalf("expect 1 connected discovery, got %v", connected)
+	}
+	// close the conn of the node too, the discovery registers it after the
+	// node got its reply
+	closed := discoveries[connected[0]]
+	closed.Close()
+	var conns []*factory.Connection
+	for i := 0; len(conns) < 1; i++ {
+		if i == 50 {
+			t.Fatal("discovery has no conn")
+		}
+		time.Sleep(100 * time.Millisecond)
+		closed.ForEachAcceptedConnection(func(key cipher.PubKey, conn *factory.Connection) {
+			conns = append(conns, conn)
+		})
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	for i := 0; ; i++ {
+		now := connectedDiscoveries(n)
+		if len(now) == 1 && now[0] != connected[0] {
+			break
+		}
+		if len(now) > 1 {
+			t.Fatalf("expect at most 1 connected discovery, got %v", now)
+		}
+		if i == 50 {
+			t.Fatalf("no other discovery connected after %s closed", connected[0])
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	n.discoveryMutex.Lock()
+	defer n.discoveryMutex.Unlock()
+	if len(n.discoveryConns) != 1 {
+		t.Fatalf("expect the closed discovery dropped, got %d discoveries", len(n.discoveryConns))
+	}
+}
+
+// Wait for the link events after since up to the one of type t
+func waitLinkEvent(t *testing.T, n *Node, since uint64, typ string) (ev NodeLinkEvent) {
+	for i := 0; ; i++ {
+		for _, ev = range n.GetLinkEvents(since) {
+			if ev.Type == typ {
+				return
+			}
+		}
+		if i == 50 {
+			t.Fatalf("no %s link event after %d, got %v", typ, since, n.GetLinkEvents(0))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestLinkEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	discovery, discoveryAddr := listenDiscovery(t, dir, "discovery")
+	defer discovery.Close()
+
+	n := New(filepath.Join(dir, "node.json"), filepath.Join(dir, "autoStart.json"), "")
+	defer n.Close()
+	// a callback may unsubscribe without deadlocking the emit
+	called := make(chan factory.LinkEvent, 1)
+	var unsubscribe func()
+	unsubscribe = n.SubscribeLinkEvents(func(ev factory.LinkEvent) {
+		unsubscribe()
+		called <- ev
+	})
+	err = n.Start(Addresses{discoveryAddr}, freeAddr(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-called:
+		if ev.Type != factory.LinkConnected {
+			t.Fatalf("expect connected, got %s", ev.Type)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback not called")
+	}
+
+	up := waitLinkEvent(t, n, 0, "connected")
+	if host := strings.Split(discoveryAddr, "-")[0]; up.Address != host {
+		t.Fatalf("expect address %s, got %s", host, up.Address)
+	}
+	var conns []*factory.Connection
+	for i := 0; len(conns) < 1; i++ {
+		if i == 50 {
+			t.Fatal("discovery has no conn")
+		}
+		time.Sleep(100 * time.Millisecond)
+		discovery.ForEachAcceptedConnection(func(key cipher.PubKey, conn *factory.Connection) {
+			conns = append(conns, conn)
+		})
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	down := waitLinkEvent(t, n, up.Seq, "disconnected")
+	if down.Address != up.Address || down.Key != up.Key {
+		t.Fatalf("expect the link of %+v, got %+v", up, down)
+	}
+	if events := n.GetLinkEvents(down.Seq); len(events) != 0 {
+		t.Fatalf("expect no events after %d, got %v", down.Seq, events)
+	}
+	select {
+	case ev := <-called:
+		t.Fatalf("unsubscribed callback called with %s", ev.Type)
+	default:
+	}
+}
+
+func TestCloseTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	discovery, discoveryAddr := listenDiscovery(t, dir, "discovery")
+	defer discovery.Close()
+
+	startNode := func(name string) (n *Node, appAddr string) {
+		n = New(filepath.Join(dir, name+".json"), filepath.Join(dir, name+"AutoStart.json"), "")
+		appAddr = freeAddr(t)
+		err := n.Start(Addresses{discoveryAddr}, appAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	n1, n1AppAddr := startNode("node1")
+	defer n1.Close()
+	n2, n2AppAddr := startNode("node2")
+	defer n2.Close()
+	n2Key, err := n2.GetNodeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the service of the server app the transport is forwarded to
+	service, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer service.Close()
+	serverSeed := filepath.Join(dir, "server.json")
+	sc, err := factory.ReadOrCreateSeedConfig(serverSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := app.NewServer(app.Public, "svc", service.Addr().String(), "")
+	err = server.Start(n2AppAddr, serverSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := app.NewClient(app.Client, "svc", "")
+	err = client.Start(n1AppAddr, filepath.Join(dir, "client.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	discoveryKey := strings.Split(discoveryAddr, "-")[1]
+	// retry until the server app service is synced to the discovery
+	for {
+		_, err = client.ConnectToContext(ctx, n2Key, sc.PublicKey, discoveryKey)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var transports []NodeTransport
+	for i := 0; len(transports) < 1; i++ {
+		if i == 50 {
+			t.Fatal("no transport listed")
+		}
+		time.Sleep(100 * time.Millisecond)
+		transports = n1.GetNodeInfo().Transports
+	}
+	id := transports[0].ID
+	if n1.CloseTransport(id + 1000) {
+		t.Fatal("expect unknown id not found")
+	}
+	if !n1.CloseTransport(id) {
+		t.Fatalf("transport %d not found", id)
+	}
+	for _, tr := range n1.GetNodeInfo().Transports {
+		if tr.ID == id {
+			t.Fatalf("transport %d still listed", id)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package node
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skywire/pkg/net/factory"
+)
+
+// ServerSelector decides which discovery servers a node connects to and in what order
+type ServerSelector interface {
+	Select(addrs Addresses) Addresses
+}
+
+// Connect to every discovery server in the configured order
+type AllSelector struct{}
+
+func (s AllSelector) Select(addrs Addresses) Addresses {
+	return addrs
+}
+
+// Connect to Max discovery servers, starting from the next server on every call.
+// The first call starts from a random server, so nodes spread over the servers.
+type RoundRobinSelector struct {
+	Max int
+
+	next    int
+	started bool
+	mutex   sync.Mutex
+}
+
+func (s *RoundRobinSelector) Select(addrs Addresses) (result Addresses) {
+	if len(addrs) < 1 {
+		return
+	}
+	s.mutex.Lock()
+	if !s.started {
+		s.started = true
+		s.next = rand.New(rand.NewSource(time.Now().UnixNano())).Intn(len(addrs))
+	}
+	start := s.next % len(addrs)
+	s.next = start + 1
+	s.mutex.Unlock()
+	for i := 0; i < len(addrs); i++ {
+		result = append(result, addrs[(start+i)%len(addrs)])
+	}
+	return limitAddresses(result, s.Max)
+}
+
+// Connect to the Max discovery servers with the lowest tcp connect time,
+// unreachable servers are skipped until the node selects again
+type LatencySelector struct {
+	Max     int
+	Timeout time.Duration
+}
+
+func (s *LatencySelector) Select(addrs Addresses) (result Addresses) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	rtts := make([]time.Duration, len(addrs))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			rtts[i] = measureLatency(addr, timeout)
+		}(i, addr)
+	}
+	wg.Wait()
+	indexes := make([]int, 0, len(addrs))
+	for i, rtt := range rtts {
+		if rtt < 0 {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return rtts[indexes[i]] < rtts[indexes[j]]
+	})
+	for _, i := range indexes {
+		result = append(result, addrs[i])
+	}
+	return limitAddresses(result, s.Max)
+}
+
+// Return the tcp connect time to the discovery address, or -1 if it is unreachable
+func measureLatency(addr string, timeout time.Duration) time.Duration {
+	split := strings.Split(addr, "-")
+	t := time.Now()
+	c, err := net.DialTimeout("tcp", factory.HostPort(split[0]), timeout)
+	if err != nil {
+		return -1
+	}
+	rtt := time.Since(t)
+	c.Close()
+	return rtt
+}
+
+// Connect to the Priority servers first in their order, followed by the rest,
+// up to Max servers
+type PrioritySelector struct {
+	Priority Addresses
+	Max      int
+}
+
+func (s *PrioritySelector) Select(addrs Addresses) (result Addresses) {
+	rest := make(map[string]struct{}, len(addrs))
+	for _, addr := range addrs {
+		rest[addr] = struct{}{}
+	}
+	for _, addr := range s.Priority {
+		if _, ok := rest[addr]; !ok {
+			continue
+		}
+		delete(rest, addr)
+		result = append(result, addr)
+	}
+	for _, addr := range addrs {
+		if _, ok := rest[addr]; !ok {
+			continue
+		}
+		result = append(result, addr)
+	}
+	return limitAddresses(result, s.Max)
+}
+
+func limitAddresses(addrs Addresses, max int) Addresses {
+	if max > 0 && len(addrs) > max {
+		return addrs[:max]
+	}
+	return addrs
+}
+
+// Create a selector by name: all, round-robin, latency or priority,
+// priority lists the servers the priority selector connects first
+func NewServerSelector(name string, max int, priority Addresses) (s ServerSelector, err error) {
+	switch name {
+	case "", "all":
+		s = AllSelector{}
+	case "round-robin":
+		s = &RoundRobinSelector{Max: max}
+	case "latency":
+		s = &LatencySelector{Max: max}
+	case "priority":
+		s = &PrioritySelector{Priority: priority, Max: max}
+	default:
+		err = fmt.Errorf("unknown server selector %s", name)
+	}
+	return
+}
@@ -0,0 +1,31 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoundRobinSelector(t *testing.T) {
+	addrs := Addresses{"a", "b", "c"}
+	s := &RoundRobinSelector{Max: 2}
+	first := s.Select(addrs)
+	if len(first) != 2 {
+		t.Fatalf("expect 2 servers, got %v", first)
+	}
+	// every call starts one server later, wherever the first call started
+	second := s.Select(addrs)
+	if second[0] != first[1] {
+		t.Fatalf("expect %v to follow %v", second, first)
+	}
+}
+
+func TestPrioritySelector(t *testing.T) {
+	s, err := NewServerSelector("priority", 3, Addresses{"c", "x", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Select(Addresses{"a", "b", "c", "d"})
+	if expect := (Addresses{"c", "b", "a"}); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expect %v, got %v", expect, got)
+	}
+}